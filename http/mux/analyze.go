@@ -0,0 +1,334 @@
+package mux
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+)
+
+// The kind of a conflict found by `Router.Analyze`.
+type ConflictKind string
+
+const (
+	// Both patterns match a path, the winner has a route of higher priority,
+	// e.g. "/users/new" shadows "/users/{id}" at "/users/new".
+	ConflictShadowed ConflictKind = "shadowed"
+	// Both patterns match a path, the winner is decided by the order of the
+	// routes of the same priority, e.g. "/{id}\d+" and "/{hex}[0-9a-f]+".
+	ConflictAmbiguous ConflictKind = "ambiguous"
+	// The pattern never matches, the other ones always win.
+	ConflictUnreachable ConflictKind = "unreachable"
+)
+
+// A conflict between two patterns, shown by a sample path.
+type Conflict struct {
+	Kind ConflictKind
+	// The pattern which loses at the path.
+	Pattern string
+	// The pattern which wins at the path.
+	Winner string
+	Path   string
+	// The host pattern, see `WithHost`. Only set by `Mux.Analyze`.
+	Host string
+}
+
+func (c Conflict) String() string {
+	str := fmt.Sprintf("%s: %q loses to %q at %q", c.Kind, c.Pattern, c.Winner, c.Path)
+	if c.Host != "" {
+		str += " of host " + c.Host
+	}
+	return str
+}
+
+// Report the patterns which can match a same path, and the ones which can
+// never match, see `ConflictKind`. Methods aren't taken into account, as the
+// path is matched first, e.g. "POST /a/b" shadows "GET /a/{x}", and
+// "GET /a/b" gets "405 Method Not Allowed".
+//
+// It's best effort. The sample paths are made of a few sample parts of each
+// route, e.g. the shortest strings matched by the regex. The winner at each
+// sample path is the one the router matches.
+func (rt *Router) Analyze() []Conflict {
+	t := rt.load()
+	conflicts := make([]Conflict, 0)
+
+	type expansion struct {
+		ep     *endpoint
+		routes []*route
+	}
+	expansions := make([]expansion, 0, len(t.endpoints))
+	for _, ep := range t.endpoints {
+		for _, routes := range expandOptionalRoutes(ep.routes) {
+			expansions = append(expansions, expansion{ep, routes})
+		}
+	}
+
+	// The unreachable ones, none of the sample paths matches.
+	for _, ep := range t.endpoints {
+		var unreached *Conflict
+		for _, routes := range expandOptionalRoutes(ep.routes) {
+			for _, path := range samplePaths(routes) {
+				l := match(t.root, path, rt.options.key(path), nil)
+				if l != nil && l.endpoint == ep {
+					unreached = nil
+					break
+				}
+				if l != nil && unreached == nil {
+					unreached = &Conflict{Kind: ConflictUnreachable, Pattern: ep.pattern, Winner: l.endpoint.pattern, Path: path}
+				}
+			}
+			if unreached == nil {
+				break
+			}
+		}
+		if unreached != nil {
+			conflicts = append(conflicts, *unreached)
+		}
+	}
+
+	// The pairs both matching a path.
+	reported := make(map[[2]*endpoint]bool)
+	for i, a := range expansions {
+		for _, b := range expansions[i+1:] {
+			if a.ep == b.ep || reported[[2]*endpoint{a.ep, b.ep}] {
+				continue
+			}
+			for _, path := range commonPaths(a.routes, b.routes) {
+				l := match(t.root, path, rt.options.key(path), nil)
+				if l == nil || (l.endpoint != a.ep && l.endpoint != b.ep) {
+					continue
+				}
+				winner, loser := a, b
+				if l.endpoint == b.ep {
+					winner, loser = b, a
+				}
+				conflicts = append(conflicts, Conflict{
+					Kind:    conflictKind(winner.routes, loser.routes),
+					Pattern: loser.ep.pattern,
+					Winner:  winner.ep.pattern,
+					Path:    path,
+				})
+				reported[[2]*endpoint{a.ep, b.ep}] = true
+				break
+			}
+		}
+	}
+	return conflicts
+}
+
+// Analyze the host-agnostic routes and the ones of each host, see
+// `Router.Analyze`.
+func (m *Mux) Analyze() []Conflict {
+	conflicts := m.router.Analyze()
+	for _, hr := range m.loadHosts() {
+		for _, c := range hr.router.Analyze() {
+			c.Host = hr.pattern
+			conflicts = append(conflicts, c)
+		}
+	}
+	return conflicts
+}
+
+// The part of testing.TB used by `AssertNoConflicts`.
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// Fail the test if there's any conflict of the kinds, or of any kind if none
+// given. `Router` and `Mux` are both analyzers. e.g.
+//
+//	func TestRoutes(t *testing.T) {
+//		mux.AssertNoConflicts(t, newAppMux(), mux.ConflictAmbiguous, mux.ConflictUnreachable)
+//	}
+func AssertNoConflicts(t TestingT, analyzer interface{ Analyze() []Conflict }, kinds ...ConflictKind) {
+	t.Helper()
+	for _, c := range analyzer.Analyze() {
+		failed := len(kinds) == 0
+		for _, kind := range kinds {
+			failed = failed || c.Kind == kind
+		}
+		if failed {
+			t.Errorf("route conflict, %s", c)
+		}
+	}
+}
+
+// Whether the winner has a route of higher priority than the loser at the
+// first part they differ.
+func conflictKind(winner, loser []*route) ConflictKind {
+	for i := 0; i < len(winner) || i < len(loser); i++ {
+		w, l := routeAt(winner, i), routeAt(loser, i)
+		if w == nil || l == nil || w.equal(l) {
+			continue
+		}
+		if w.priority != l.priority {
+			return ConflictShadowed
+		}
+		break
+	}
+	return ConflictAmbiguous
+}
+
+// The route matching the i-th part of a path, the wildcard one matches the
+// rest of the path. Returns nil if none.
+func routeAt(routes []*route, i int) *route {
+	if i < len(routes) {
+		return routes[i]
+	}
+	if len(routes) > 0 && routes[len(routes)-1].priority == kWildcardPattern {
+		return routes[len(routes)-1]
+	}
+	return nil
+}
+
+const maxSamples = 16
+
+// Sample paths matched by the routes.
+func samplePaths(routes []*route) []string {
+	parts := make([][]string, 0, len(routes))
+	for _, r := range routes {
+		parts = append(parts, r.samples())
+	}
+	return joinSamples(parts)
+}
+
+// Sample paths matched by both of the routes.
+func commonPaths(a, b []*route) []string {
+	parts := make([][]string, 0)
+	for i := 0; i < len(a) || i < len(b); i++ {
+		ra, rb := routeAt(a, i), routeAt(b, i)
+		if ra == nil || rb == nil {
+			return nil
+		}
+		if ra.priority == kWildcardPattern && rb.priority == kWildcardPattern {
+			parts = append(parts, ra.samples())
+			break
+		}
+		samples := make([]string, 0)
+		for _, s := range append(ra.samples(), rb.samples()...) {
+			if ra.match(s) && rb.match(s) {
+				samples = append(samples, s)
+			}
+		}
+		if len(samples) == 0 {
+			return nil
+		}
+		parts = append(parts, samples)
+	}
+	return joinSamples(parts)
+}
+
+// Join the sample parts to the paths, at most `maxSamples` ones.
+func joinSamples(parts [][]string) []string {
+	paths := []string{""}
+	for _, samples := range parts {
+		next := make([]string, 0, maxSamples)
+		for _, path := range paths {
+			for _, s := range samples {
+				if len(next) < maxSamples {
+					next = append(next, path+"/"+s)
+				}
+			}
+		}
+		paths = next
+	}
+	if len(parts) == 0 {
+		return []string{"/"}
+	}
+	return paths
+}
+
+// Sample parts matched by the route.
+func (r *route) samples() []string {
+	switch r.priority {
+	case kAbsolutePattern:
+		return []string{r.pattern}
+	case kAnyPattern, kWildcardPattern:
+		return []string{"x", "0", "x-0.y"}
+	}
+	return regexSamples(r.regex)
+}
+
+// Sample strings matched by the regex, the shortest ones of each branch.
+func regexSamples(re *regexp.Regexp) []string {
+	parsed, err := syntax.Parse(re.String(), syntax.Perl)
+	if err != nil {
+		return nil
+	}
+	samples := make([]string, 0)
+	for _, s := range sampleSyntax(parsed.Simplify()) {
+		if re.MatchString(s) {
+			samples = append(samples, s)
+		}
+	}
+	return samples
+}
+
+func sampleSyntax(re *syntax.Regexp) []string {
+	switch re.Op {
+	case syntax.OpLiteral:
+		return []string{string(re.Rune)}
+	case syntax.OpCharClass:
+		return sampleCharClass(re.Rune)
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		return []string{"x"}
+	case syntax.OpCapture, syntax.OpPlus:
+		return sampleSyntax(re.Sub[0])
+	case syntax.OpStar, syntax.OpQuest:
+		return limitSamples(append([]string{""}, sampleSyntax(re.Sub[0])...))
+	case syntax.OpRepeat:
+		samples := make([]string, 0)
+		for _, s := range sampleSyntax(re.Sub[0]) {
+			samples = append(samples, strings.Repeat(s, re.Min))
+		}
+		return limitSamples(samples)
+	case syntax.OpConcat:
+		samples := []string{""}
+		for _, sub := range re.Sub {
+			next := make([]string, 0)
+			for _, prefix := range samples {
+				for _, s := range sampleSyntax(sub) {
+					next = append(next, prefix+s)
+				}
+			}
+			samples = limitSamples(next)
+		}
+		return samples
+	case syntax.OpAlternate:
+		samples := make([]string, 0)
+		for _, sub := range re.Sub {
+			samples = append(samples, sampleSyntax(sub)...)
+		}
+		return limitSamples(samples)
+	}
+	// The empty ones, e.g. "^" and "$".
+	return []string{""}
+}
+
+// Pick a few readable runes of the class, given as the ranges of [lo, hi].
+func sampleCharClass(ranges []rune) []string {
+	samples := make([]string, 0)
+	for _, c := range "x0X-_." {
+		for i := 0; i+1 < len(ranges); i += 2 {
+			if ranges[i] <= c && c <= ranges[i+1] {
+				samples = append(samples, string(c))
+				break
+			}
+		}
+	}
+	for i := 0; i+1 < len(ranges) && len(samples) < 3; i += 2 {
+		if lo := ranges[i]; lo > ' ' && lo != '/' && !strings.ContainsRune("x0X-_.", lo) {
+			samples = append(samples, string(lo))
+		}
+	}
+	return limitSamples(samples)
+}
+
+func limitSamples(samples []string) []string {
+	if len(samples) > maxSamples/2 {
+		return samples[:maxSamples/2]
+	}
+	return samples
+}
@@ -0,0 +1,145 @@
+package mux
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type contextKey int
+
+const routeKey contextKey = 0
+
+// The route matched by `Mux`, stored in the request context.
+type routeContext struct {
+	pattern string
+	vars    RouteVariables
+	values  RouteValues
+	// The metadata of the handler matched, see `WithMetadata`.
+	metadata Metadata
+	// The pattern prefix the current mux is mounted at, see `Mux.Mount`.
+	mount string
+}
+
+func routeFrom(ctx context.Context) *routeContext {
+	rc, _ := ctx.Value(routeKey).(*routeContext)
+	if rc == nil {
+		return &routeContext{}
+	}
+	return rc
+}
+
+// Get the route variables stored in the context by `Mux`.
+func RouteVarsFrom(ctx context.Context) RouteVariables {
+	return routeFrom(ctx).vars
+}
+
+func RouteVars(r *http.Request) map[string]string {
+	return RouteVarsFrom(r.Context())
+}
+
+// Get the route variable by name. Returns "" if not found.
+func RouteVar(r *http.Request, name string) string {
+	return RouteVarsFrom(r.Context())[name]
+}
+
+// Get the route variable by name and convert it to int.
+func RouteVarInt(r *http.Request, name string) (int, error) {
+	v, ok := RouteVarsFrom(r.Context())[name]
+	if !ok {
+		return 0, fmt.Errorf("route variable %q not found", name)
+	}
+	return strconv.Atoi(v)
+}
+
+// Get the pattern of the route matched by `Mux`. Returns "" if not found.
+func RoutePatternFrom(ctx context.Context) string {
+	return routeFrom(ctx).pattern
+}
+
+func RoutePattern(r *http.Request) string {
+	return RoutePatternFrom(r.Context())
+}
+
+// Get the typed values of the route variables with converters stored in the
+// context by `Mux`, see `Converter`.
+func RouteValuesFrom(ctx context.Context) RouteValues {
+	return routeFrom(ctx).values
+}
+
+// Get the typed value of the route variable by name, e.g. an int of
+// "{id:int}". Returns nil if not found.
+func RouteValue(r *http.Request, name string) interface{} {
+	return RouteValuesFrom(r.Context())[name]
+}
+
+// Get the int value of the route variable by name, e.g. of "{id:int}".
+func RouteValueInt(r *http.Request, name string) (int, bool) {
+	v, ok := RouteValue(r, name).(int)
+	return v, ok
+}
+
+// Get the string value of the route variable by name, e.g. of "{id:uuid}" and
+// "{title:slug}".
+func RouteValueString(r *http.Request, name string) (string, bool) {
+	v, ok := RouteValue(r, name).(string)
+	return v, ok
+}
+
+// Get the time value of the route variable by name, e.g. of "{day:date}".
+func RouteValueTime(r *http.Request, name string) (time.Time, bool) {
+	v, ok := RouteValue(r, name).(time.Time)
+	return v, ok
+}
+
+// Get the metadata of the handler matched by `Mux`, see `WithMetadata`.
+// Returns nil if none.
+func RouteMetadataFrom(ctx context.Context) Metadata {
+	return routeFrom(ctx).metadata
+}
+
+// Get the metadata of the handler matched by `Mux` by key, e.g. in a
+// middleware checking the auth scopes of the route. Returns nil if not found.
+func RouteMetadata(r *http.Request, key string) interface{} {
+	return RouteMetadataFrom(r.Context())[key]
+}
+
+// Store the route matched in the request context. If the mux is mounted, the
+// pattern is prefixed by the mount one and the variables and the metadata are
+// merged into the parent ones.
+func setRoute(r *http.Request, matched *routeContext) *http.Request {
+	parent := routeFrom(r.Context())
+	rc := matched
+	if parent.mount != "" {
+		rc = &routeContext{values: matched.values, metadata: matched.metadata, mount: parent.mount}
+		rc.pattern = parent.mount + matched.pattern
+		rc.vars = make(RouteVariables, len(parent.vars)+len(matched.vars))
+		for k, v := range parent.vars {
+			rc.vars[k] = v
+		}
+		for k, v := range matched.vars {
+			rc.vars[k] = v
+		}
+		if len(parent.values) > 0 {
+			rc.values = make(RouteValues, len(parent.values)+len(matched.values))
+			for k, v := range parent.values {
+				rc.values[k] = v
+			}
+			for k, v := range matched.values {
+				rc.values[k] = v
+			}
+		}
+		if len(parent.metadata) > 0 {
+			rc.metadata = make(Metadata, len(parent.metadata)+len(matched.metadata))
+			for k, v := range parent.metadata {
+				rc.metadata[k] = v
+			}
+			for k, v := range matched.metadata {
+				rc.metadata[k] = v
+			}
+		}
+	}
+	return r.WithContext(context.WithValue(r.Context(), routeKey, rc))
+}
@@ -0,0 +1,115 @@
+package mux
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A converter parses a route variable to a typed value, e.g. "{id:int}" makes
+// an int of the part. The part which fails to convert doesn't match the route.
+// See `RegisterConverter`.
+type Converter struct {
+	// The regex of the part, e.g. `-?\d+`.
+	Regex string
+	// Convert the part matched by the regex to the typed value.
+	Convert func(string) (interface{}, error)
+}
+
+// The typed values of the route variables with converters, see `Converter`.
+type RouteValues map[string]interface{}
+
+var (
+	convertersMutex sync.RWMutex
+	converters      = map[string]*Converter{
+		// Converted to int.
+		"int": {Regex: `-?\d+`, Convert: func(s string) (interface{}, error) { return strconv.Atoi(s) }},
+		// Converted to the lower case string, e.g. "123e4567-e89b-12d3-a456-426614174000".
+		"uuid": {
+			Regex:   `[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`,
+			Convert: func(s string) (interface{}, error) { return strings.ToLower(s), nil },
+		},
+		// Converted to time.Time in UTC, e.g. "2006-01-02". Invalid days like
+		// "2023-02-30" fail.
+		"date": {
+			Regex:   `\d{4}-\d{2}-\d{2}`,
+			Convert: func(s string) (interface{}, error) { return time.Parse("2006-01-02", s) },
+		},
+		// Converted to string, e.g. "hello-world-2".
+		"slug": {Regex: `[a-z0-9]+(?:-[a-z0-9]+)*`, Convert: func(s string) (interface{}, error) { return s, nil }},
+	}
+	converterName = regexp.MustCompile(`^[A-Za-z_]\w*$`)
+)
+
+// Register the converter by name, so patterns can use it as "{var:name}".
+// Register it before the patterns using it, or the name is taken as a regex.
+// Panics if the name is taken, or the converter is invalid.
+// e.g. RegisterConverter("hex", Converter{`[0-9a-f]+`, parseHex})
+func RegisterConverter(name string, c Converter) {
+	if !converterName.MatchString(name) {
+		panic(fmt.Errorf("invalid converter name %q", name))
+	}
+	if c.Convert == nil {
+		panic(fmt.Errorf("converter %q without Convert", name))
+	}
+	if _, err := regexp.Compile(c.Regex); err != nil {
+		panic(fmt.Errorf("invalid regex of converter %q: %s", name, err))
+	}
+
+	convertersMutex.Lock()
+	defer convertersMutex.Unlock()
+	if _, ok := converters[name]; ok {
+		panic(fmt.Errorf("converter %q already registered", name))
+	}
+	converters[name] = &c
+}
+
+// Returns nil if not found.
+func lookupConverter(name string) *Converter {
+	convertersMutex.RLock()
+	defer convertersMutex.RUnlock()
+	return converters[name]
+}
+
+// A route variable with a converter, see `route.converters`.
+type routeConverter struct {
+	// The name of the route variable, and the one of the converter.
+	name, converter string
+	*Converter
+}
+
+// Convert the route variables of the part, which the regex of the route
+// matched. Returns false if any fails.
+func (r *route) convert(part string, values RouteValues) bool {
+	submatches := r.regex.FindStringSubmatch(part)
+	if submatches == nil {
+		return false
+	}
+	for _, rc := range r.converters {
+		value, err := rc.Convert(submatches[r.regex.SubexpIndex(rc.name)])
+		if err != nil {
+			return false
+		}
+		if values != nil {
+			values[rc.name] = value
+		}
+	}
+	return true
+}
+
+// Report whether the routes convert the variables in the same order by the
+// same converters, regardless of the names of the variables.
+func sameConverters(a, b []routeConverter) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].converter != b[i].converter {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,213 @@
+package mux
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// A step of the lookup of a path, see `Router.Explain`.
+type ExplainStep struct {
+	// The depth in the tree, the children of the root are 0.
+	Depth int
+	// The node tried, i.e. its static text or the part of its route.
+	Node string
+	// "static", "partial", "regex", "any" or "wildcard", see `RoutePart`.
+	Kind string
+	// The rest of the path the static node is tried on, or the part of the
+	// path the dynamic one is tried on.
+	Segment string
+	// "match" or "mismatch" for a node tried, "backtrack" if none below the
+	// node matches the rest of the path, then the next one is tried, or "leaf"
+	// if the path ends at a pattern.
+	Action string
+	// Why, e.g. `regex "^\d+$"`.
+	Reason string
+}
+
+func (s ExplainStep) String() string {
+	str := fmt.Sprintf("%s%s %s", strings.Repeat("  ", s.Depth), s.Action, s.Node)
+	if s.Segment != "" {
+		str += fmt.Sprintf(" on %q", s.Segment)
+	}
+	if s.Reason != "" {
+		str += ", " + s.Reason
+	}
+	return str
+}
+
+// How a path is matched by the router, see `Router.Explain`.
+type Explanation struct {
+	Path string
+	// The host pattern of the router, see `WithHost`. Only set by `Mux.Explain`.
+	Host  string
+	Steps []ExplainStep
+	// The pattern matching the path, "" if none.
+	Pattern   string
+	RouteVars RouteVariables
+	// The patterns matched on the way of the path if none matches the full
+	// path, which `Mux` falls back to, e.g. "/a/" of path "/a/b". The handlers
+	// are left nil, as the method is unknown.
+	HandlersOnTheWay []RouteMatchItem
+}
+
+// One line of the final choice, e.g. to be put in a header.
+func (x *Explanation) Summary() string {
+	backtracks := 0
+	for _, s := range x.Steps {
+		if s.Action == "backtrack" {
+			backtracks++
+		}
+	}
+	str := fmt.Sprintf("%d steps, %d backtracks", len(x.Steps), backtracks)
+	if x.Pattern != "" {
+		str = fmt.Sprintf("matched %q, %s", x.Pattern, str)
+	} else {
+		str = "no match, " + str
+		for _, item := range x.HandlersOnTheWay {
+			str += fmt.Sprintf(", %q on the way at %q", item.Pattern, item.Path)
+		}
+	}
+	if x.Host != "" {
+		str += ", host " + x.Host
+	}
+	return str
+}
+
+func (x *Explanation) String() string {
+	buf := new(strings.Builder)
+	fmt.Fprintf(buf, "path %q\n", x.Path)
+	for _, s := range x.Steps {
+		fmt.Fprintf(buf, "  %s\n", s)
+	}
+	fmt.Fprintf(buf, "%s\n", x.Summary())
+	if len(x.RouteVars) > 0 {
+		names := make([]string, 0, len(x.RouteVars))
+		for name := range x.RouteVars {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(buf, "  %s = %q\n", name, x.RouteVars[name])
+		}
+	}
+	return buf.String()
+}
+
+// Explain step by step how the path is matched, regardless of the method: the
+// nodes tried, which part of the path matches which node and why, where it
+// backtracks, the patterns matched on the way and the final choice. It's for
+// debugging, the lookup is slower than `Match`.
+func (rt *Router) Explain(path string) *Explanation {
+	root := rt.load().root
+	x := &Explanation{Path: path, Steps: make([]ExplainStep, 0)}
+	path = rt.options.normalize(path)
+	key := rt.options.key(path)
+	var l *leaf
+	switch {
+	case path == "" || path == "/":
+		if l = root.find(tokenize(nil)); l != nil {
+			x.Steps = append(x.Steps, ExplainStep{Node: "/", Kind: "static", Action: "leaf",
+				Reason: fmt.Sprintf("pattern %q ends here", l.endpoint.pattern)})
+		}
+	case strings.HasSuffix(path, "//"):
+		x.Steps = append(x.Steps, ExplainStep{Segment: path, Action: "mismatch", Reason: "multiple trailing \"/\""})
+	default:
+		l = root.lookup(path, key, 0, nil, &explainer{steps: &x.Steps})
+	}
+
+	if l != nil {
+		x.Pattern = l.endpoint.pattern
+		x.RouteVars = l.vars(path)
+		return x
+	}
+	for _, item := range trace(root, path, key) {
+		x.HandlersOnTheWay = append(x.HandlersOnTheWay, RouteMatchItem{Path: item.path, Pattern: item.leaf.endpoint.pattern})
+	}
+	return x
+}
+
+// Explain the request on the router it's matched by, i.e. the first one of the
+// hosts matching the full path, or the host-agnostic one. See `Router.Explain`.
+func (m *Mux) Explain(r *http.Request) *Explanation {
+	r = m.withAPIVersion(r)
+	np := r.URL.Path
+	if m.Versioning != nil {
+		np = m.Versioning.trimPath(np)
+	}
+	for _, hr := range m.loadHosts() {
+		if !hr.match(r.Host, make(RouteVariables)) {
+			continue
+		}
+		if x := hr.router.Explain(np); x.Pattern != "" {
+			x.Host = hr.pattern
+			return x
+		}
+	}
+	return m.router.Explain(np)
+}
+
+// A middleware putting the summary of `Mux.Explain` of each request in the
+// "X-Route-Explain" response header. It's for debugging, don't use it in
+// production. e.g. m.Use(mux.ExplainMiddleware(m))
+func ExplainMiddleware(m *Mux) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			rw.Header().Set("X-Route-Explain", m.Explain(r).Summary())
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
+
+// Records the steps of `node.lookup`, shared by the nodes on the way.
+type explainer struct {
+	steps *[]ExplainStep
+	depth int
+}
+
+// The explainer of the children of the node tried. Returns nil if e is nil.
+func (e *explainer) enter() *explainer {
+	if e == nil {
+		return nil
+	}
+	return &explainer{steps: e.steps, depth: e.depth + 1}
+}
+
+func (e *explainer) step(n *node, segment, action, reason string) {
+	if e == nil {
+		return
+	}
+	s := ExplainStep{Depth: e.depth, Node: n.prefix, Kind: "static", Segment: segment, Action: action, Reason: reason}
+	if n.route != nil {
+		s.Node, s.Kind = n.route.part, routeKinds[n.route.priority]
+	}
+	*e.steps = append(*e.steps, s)
+}
+
+// Record the node tried on the segment, with the reason it matches or not.
+func (e *explainer) try(n *node, segment string, matched bool) {
+	if e == nil {
+		return
+	}
+	action := "mismatch"
+	if matched {
+		action = "match"
+	}
+	var reason string
+	switch {
+	case n.route == nil:
+		reason = fmt.Sprintf("literal %q", n.prefix)
+	case n.route.priority == kAnyPattern:
+		reason = "any non-empty part"
+	case n.route.priority == kWildcardPattern:
+		reason = "the rest of the path"
+	default:
+		reason = fmt.Sprintf("regex %q", n.route.regex.String())
+	}
+	e.step(n, segment, action, reason)
+}
+
+func (e *explainer) backtrack(n *node, segment string) {
+	e.step(n, segment, "backtrack", "nothing below matches the rest of the path")
+}
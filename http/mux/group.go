@@ -0,0 +1,79 @@
+package mux
+
+import (
+	"net/http"
+	"strings"
+)
+
+// A group of routes sharing a pattern prefix and route options, e.g.
+//
+// api := mux.Group("/api/v1", WithMiddleware(auth))
+// api.Get("/users/{id}\\d+", showUser) // "/api/v1/users/{id}\\d+"
+// api.Mount("/blog", blogMux)         // "/api/v1/blog/..."
+type Group struct {
+	mux    *Mux
+	prefix string
+	opts   []RouteOption
+}
+
+// Create a group of routes under the prefix. The options are applied to every
+// route in the group, before the ones given when registering a route.
+func (m *Mux) Group(prefix string, opts ...RouteOption) *Group {
+	return &Group{
+		mux:    m,
+		prefix: strings.TrimRight(strings.TrimSpace(prefix), "/"),
+		opts:   opts,
+	}
+}
+
+// Create a sub group, which inherits the prefix and the options of the group.
+func (g *Group) Group(prefix string, opts ...RouteOption) *Group {
+	return g.mux.Group(g.prefix+strings.TrimSpace(prefix), g.options(opts)...)
+}
+
+// Add middlewares shared by the routes of the group.
+// NB: Only the routes registered afterwards are affected.
+func (g *Group) Use(mw ...func(http.Handler) http.Handler) {
+	g.opts = append(g.opts, WithMiddleware(mw...))
+}
+
+func (g *Group) options(opts []RouteOption) []RouteOption {
+	return append(append([]RouteOption(nil), g.opts...), opts...)
+}
+
+func (g *Group) Handle(pattern string, handler http.Handler, opts ...RouteOption) {
+	g.HandleMethod("", pattern, handler, opts...)
+}
+
+func (g *Group) HandleFunc(pattern string, fn http.HandlerFunc, opts ...RouteOption) {
+	g.Handle(pattern, http.HandlerFunc(fn), opts...)
+}
+
+func (g *Group) HandleMethod(method, pattern string, handler http.Handler, opts ...RouteOption) {
+	g.mux.HandleMethod(method, g.prefix+strings.TrimSpace(pattern), handler, g.options(opts)...)
+}
+
+func (g *Group) Get(pattern string, fn http.HandlerFunc, opts ...RouteOption) {
+	g.HandleMethod("GET", pattern, fn, opts...)
+}
+
+func (g *Group) Post(pattern string, fn http.HandlerFunc, opts ...RouteOption) {
+	g.HandleMethod("POST", pattern, fn, opts...)
+}
+
+func (g *Group) Put(pattern string, fn http.HandlerFunc, opts ...RouteOption) {
+	g.HandleMethod("PUT", pattern, fn, opts...)
+}
+
+func (g *Group) Patch(pattern string, fn http.HandlerFunc, opts ...RouteOption) {
+	g.HandleMethod("PATCH", pattern, fn, opts...)
+}
+
+func (g *Group) Delete(pattern string, fn http.HandlerFunc, opts ...RouteOption) {
+	g.HandleMethod("DELETE", pattern, fn, opts...)
+}
+
+// Mount the handler under the prefix of the group. See `Mux.Mount`.
+func (g *Group) Mount(prefix string, handler http.Handler, opts ...RouteOption) {
+	g.mux.Mount(g.prefix+strings.TrimSpace(prefix), handler, g.options(opts)...)
+}
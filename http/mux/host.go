@@ -0,0 +1,109 @@
+package mux
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+)
+
+// The routes registered with `WithHost` on the same host pattern.
+type hostRouter struct {
+	pattern string
+	regex   *regexp.Regexp
+	router  *Router
+	// Whether the pattern has an explicit port, e.g. "localhost:8080".
+	port bool
+}
+
+// Compile a host pattern, e.g. "{tenant}.example.com" or "api.{region:[a-z]{2}}.example.com",
+// to a regex capturing the route variables by name. Hosts are case-insensitive,
+// and a variable without regex matches a label, i.e. no dots.
+func compileHostPattern(pattern string) (*regexp.Regexp, error) {
+	buf := bytes.NewBufferString("(?i)^")
+	for _, piece := range splitPartialRoute(pattern) {
+		if piece.name == "" {
+			buf.WriteString(regexp.QuoteMeta(piece.text))
+			continue
+		}
+		regex := piece.text
+		if regex == "" {
+			regex = "[^.]+"
+		}
+		fmt.Fprintf(buf, "(?P<%s>%s)", piece.name, regex)
+	}
+	buf.WriteString("$")
+
+	regex, err := regexp.Compile(buf.String())
+	if err != nil {
+		return nil, fmt.Errorf("unable to compile host %q, compile error: %s", pattern, err.Error())
+	}
+	return regex, nil
+}
+
+// Lowercase the literal text of the host pattern, since hosts are
+// case-insensitive, but keep the names of the route variables as written, e.g.
+// "{tenantID}.Example.com" to "{tenantID}.example.com".
+func normalizeHostPattern(pattern string) string {
+	pattern = strings.TrimSpace(pattern)
+	var buf strings.Builder
+	for {
+		start, end := indexRouteVar(pattern)
+		if start < 0 {
+			buf.WriteString(strings.ToLower(pattern))
+			return buf.String()
+		}
+		buf.WriteString(strings.ToLower(pattern[:start]))
+		buf.WriteString(pattern[start:end])
+		pattern = pattern[end:]
+	}
+}
+
+// Report whether the host pattern has an explicit port, i.e. a ":" out of the
+// route variables, e.g. the one of "{name}.local:8080" but "{region:[a-z]{2}}.example.com".
+func hostPatternHasPort(pattern string) bool {
+	for _, piece := range splitPartialRoute(pattern) {
+		if piece.name == "" && strings.Contains(piece.text, ":") {
+			return true
+		}
+	}
+	return false
+}
+
+// Report whether the host matches, and fill in the route variables captured.
+func (hr *hostRouter) match(host string, rvs RouteVariables) bool {
+	// Leave out the port unless the pattern has one.
+	if !hr.port {
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+	}
+
+	submatches := hr.regex.FindStringSubmatch(host)
+	if submatches == nil {
+		return false
+	}
+	for i, name := range hr.regex.SubexpNames() {
+		if name != "" {
+			rvs[name] = submatches[i]
+		}
+	}
+	return true
+}
+
+// Hosts without route variables first, then the longer ones first.
+type byHostPriority []*hostRouter
+
+func (b byHostPriority) Len() int      { return len(b) }
+func (b byHostPriority) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b byHostPriority) Less(i, j int) bool {
+	vi, vj := strings.Contains(b[i].pattern, "{"), strings.Contains(b[j].pattern, "{")
+	if vi != vj {
+		return vj
+	}
+	if len(b[i].pattern) != len(b[j].pattern) {
+		return len(b[i].pattern) > len(b[j].pattern)
+	}
+	return b[i].pattern < b[j].pattern
+}
@@ -0,0 +1,80 @@
+package mux
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// A matcher tells whether a route matches the request besides its path and
+// method. Handlers can share a pattern and be chosen by their matchers, see
+// `WithMatchers`.
+type Matcher interface {
+	Match(r *http.Request) bool
+}
+
+type MatcherFunc func(r *http.Request) bool
+
+func (fn MatcherFunc) Match(r *http.Request) bool { return fn(r) }
+
+// Match the requests with the header. If value is "", the header only needs to
+// be present. e.g. MatchHeader("X-Api-Key", "")
+func MatchHeader(name, value string) Matcher {
+	return MatcherFunc(func(r *http.Request) bool {
+		values, ok := r.Header[http.CanonicalHeaderKey(name)]
+		if !ok {
+			return false
+		}
+		if value == "" {
+			return true
+		}
+		for _, v := range values {
+			if v == value {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Match the requests with the query parameter. If value is "", the parameter
+// only needs to be present. e.g. MatchQuery("format", "csv")
+func MatchQuery(name, value string) Matcher {
+	return MatcherFunc(func(r *http.Request) bool {
+		values, ok := r.URL.Query()[name]
+		if !ok {
+			return false
+		}
+		if value == "" {
+			return true
+		}
+		for _, v := range values {
+			if v == value {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Match the requests whose "Content-Type" is any of the media types, parameters
+// like "charset" are ignored. A type like "text/*" matches all its subtypes.
+// e.g. MatchContentType("application/json", "text/*")
+func MatchContentType(types ...string) Matcher {
+	return MatcherFunc(func(r *http.Request) bool {
+		mediatype, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil {
+			return false
+		}
+		for _, t := range types {
+			t = strings.ToLower(strings.TrimSpace(t))
+			if t == mediatype {
+				return true
+			}
+			if strings.HasSuffix(t, "/*") && strings.HasPrefix(mediatype, t[:len(t)-1]) {
+				return true
+			}
+		}
+		return false
+	})
+}
@@ -1,47 +1,236 @@
 package mux
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"net/http"
+	"net/url"
 	"path"
+	"sort"
 	"strings"
-
-	"github.com/gorilla/context"
+	"sync"
+	"sync/atomic"
 )
 
-func RouteVars(r *http.Request) map[string]string {
-	if rv := context.Get(r, 0); rv != nil {
-		return map[string]string(rv.(RouteVariables))
-	}
-
-	return nil
-}
+// A middleware wraps a handler with extra work, e.g. logging, auth and recovery.
+type Middleware func(http.Handler) http.Handler
 
-func setRouteVars(r *http.Request, rvs RouteVariables) {
-	fmt.Printf("setVars(%p, %v)\n", r, rvs)
-	context.Set(r, 0, rvs)
+// Wrap the handler with the middlewares, the first one is the outermost.
+func chain(h http.Handler, mws []Middleware) http.Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
 }
 
 type Mux struct {
 	router          *Router
 	NotFoundHandler http.Handler
+
+	// By default, "HEAD" requests are served by the "GET" handler with the
+	// body discarded, and "OPTIONS" requests are answered with the allowed
+	// methods in the "Allow" header. Handlers bound to "HEAD" or "OPTIONS"
+	// explicitly always take precedence.
+	DisableAutoHead    bool
+	DisableAutoOptions bool
+
+	middlewares []Middleware
+	// The named routes, see `WithName`.
+	names map[string]namedRoute
+	// Guards `names`, the routes can be changed while serving, see `Remove`.
+	namesMutex sync.RWMutex
+	// Routers of the routes registered with `WithHost`, tried before the
+	// host-agnostic one, see `byHostPriority`. Copied on write, like the
+	// route table, see `loadHosts`.
+	hosts atomic.Value // []*hostRouter
+	// Guards the writers of `hosts`.
+	hostsMutex sync.Mutex
+
+	// API versioning, nil to disable. See `Versioning` and `WithVersion`.
+	Versioning *Versioning
+	// Options of the routers, see `NewMux`.
+	routerOptions []RouterOption
+
+	// See `SlashPolicy`, `RedirectToSlash` by default.
+	SlashPolicy SlashPolicy
+	// By default, the paths are cleaned before matching, i.e. ".." and "."
+	// segments (percent-encoded or not) and multiple "/" are resolved, and the
+	// requests are redirected to the cleaned paths.
+	DisableCleanPath bool
+	// Redirect the requests to the path spelled as the pattern matched, e.g.
+	// "/Users" to "/users" with `IgnoreCase`. See `NewMux`.
+	RedirectCanonical bool
+	// The status code of the redirects, one of 301, 302, 307 and 308. By
+	// default, 301 for the cleaned paths and 302 for the trailing "/". Use 307
+	// or 308 to keep the method and the body, e.g. of "POST" requests.
+	RedirectCode int
 }
 
-func NewMux() *Mux {
+// Make a mux, the options apply to the routers of all the hosts.
+// e.g. mux.NewMux(mux.IgnoreCase(), mux.NormalizeUnicode())
+func NewMux(opts ...RouterOption) *Mux {
 	return &Mux{
-		router: NewRouter(),
+		router:        NewRouter(opts...),
+		names:         make(map[string]namedRoute),
+		routerOptions: opts,
 	}
 }
 
-func (m *Mux) Handle(pattern string, handler http.Handler) {
-	err := m.router.Handle(pattern, handler)
+// Add global middlewares, which wrap every request served by the mux, including
+// the "not found" ones and the redirects. They are applied in the order added
+// and before the per-route middlewares, i.e. the first one is the outermost.
+func (m *Mux) Use(mw ...func(http.Handler) http.Handler) {
+	for _, x := range mw {
+		m.middlewares = append(m.middlewares, x)
+	}
+}
+
+// Bind the handler to the pattern for any HTTP method. Methods bound by
+// `HandleMethod` on the same pattern take precedence.
+func (m *Mux) Handle(pattern string, handler http.Handler, opts ...RouteOption) {
+	m.HandleMethod("", pattern, handler, opts...)
+}
+
+func (m *Mux) HandleFunc(pattern string, fn http.HandlerFunc, opts ...RouteOption) {
+	m.Handle(pattern, http.HandlerFunc(fn), opts...)
+}
+
+// Bind the handler to the pattern for the specified HTTP method only.
+// Requests to the pattern with a method not bound get "405 Method Not Allowed".
+func (m *Mux) HandleMethod(method, pattern string, handler http.Handler, opts ...RouteOption) {
+	ro := newRouteOptions(opts)
+	rt := m.router
+	if ro.host != "" {
+		rt = m.hostRouter(ro.host)
+	}
+	err := m.bind(rt, pattern, handler, ro, func(pattern string, h http.Handler) error {
+		return rt.HandleMethod(method, pattern, h, opts...)
+	})
 	if err != nil {
 		panic(err)
 	}
 }
 
-func (m *Mux) HandleFunc(pattern string, fn http.HandlerFunc) {
-	m.Handle(pattern, http.HandlerFunc(fn))
+// Replace all of the handlers bound to the pattern with the handler, which
+// serves any HTTP method. The options are the same as `Handle`, use `WithHost`
+// to replace the one of a host. See `Router.Replace`.
+func (m *Mux) Replace(pattern string, handler http.Handler, opts ...RouteOption) error {
+	ro := newRouteOptions(opts)
+	rt, err := m.routerOf(ro.host)
+	if err != nil {
+		return err
+	}
+	return m.bind(rt, pattern, handler, ro, func(pattern string, h http.Handler) error {
+		return rt.Replace(pattern, h, opts...)
+	})
+}
+
+// Replace the handlers bound to the method on the pattern with the handler.
+// See `Replace`.
+func (m *Mux) ReplaceMethod(method, pattern string, handler http.Handler, opts ...RouteOption) error {
+	ro := newRouteOptions(opts)
+	rt, err := m.routerOf(ro.host)
+	if err != nil {
+		return err
+	}
+	return m.bind(rt, pattern, handler, ro, func(pattern string, h http.Handler) error {
+		return rt.ReplaceMethod(method, pattern, h, opts...)
+	})
+}
+
+// Bind the handler wrapped by the per-route middlewares with fn, and name the
+// pattern if `WithName` is given.
+func (m *Mux) bind(rt *Router, pattern string, handler http.Handler, ro *routeOptions,
+	fn func(pattern string, h http.Handler) error) error {
+	pattern = strings.TrimSpace(pattern)
+
+	nr := namedRoute{host: normalizeHostPattern(ro.host), pattern: pattern}
+
+	m.namesMutex.Lock()
+	defer m.namesMutex.Unlock()
+	if ro.name != "" {
+		if x, exists := m.names[ro.name]; exists && x != nr {
+			return fmt.Errorf("route name %q already used by pattern %q", ro.name, x.pattern)
+		}
+	}
+	if handler != nil {
+		handler = chain(handler, ro.middlewares)
+	}
+	if err := fn(pattern, handler); err != nil {
+		return err
+	}
+	if ro.name != "" {
+		m.names[ro.name] = nr
+	}
+	return nil
+}
+
+// A route named by `WithName`, the names are unique across the hosts.
+type namedRoute struct {
+	// The host pattern of the router, "" of the host-agnostic one.
+	host    string
+	pattern string
+}
+
+// Remove the pattern together with all of the handlers bound to it, and the
+// names of it. Only `WithHost` of the options is used, to remove the one of a
+// host. See `Router.Remove`.
+func (m *Mux) Remove(pattern string, opts ...RouteOption) error {
+	return m.remove(pattern, opts, func(rt *Router, pattern string) error {
+		return rt.Remove(pattern)
+	})
+}
+
+// Remove the handlers bound to the method on the pattern. See `Remove`.
+func (m *Mux) RemoveMethod(method, pattern string, opts ...RouteOption) error {
+	return m.remove(pattern, opts, func(rt *Router, pattern string) error {
+		return rt.RemoveMethod(method, pattern)
+	})
+}
+
+func (m *Mux) remove(pattern string, opts []RouteOption, fn func(rt *Router, pattern string) error) error {
+	pattern = strings.TrimSpace(pattern)
+	host := newRouteOptions(opts).host
+	rt, err := m.routerOf(host)
+	if err != nil {
+		return err
+	}
+	removed := namedRoute{host: normalizeHostPattern(host), pattern: pattern}
+
+	m.namesMutex.Lock()
+	defer m.namesMutex.Unlock()
+	if err := fn(rt, pattern); err != nil {
+		return err
+	}
+	if !rt.PatternExists(pattern) {
+		for name, x := range m.names {
+			if x == removed {
+				delete(m.names, name)
+			}
+		}
+	}
+	return nil
+}
+
+func (m *Mux) Get(pattern string, fn http.HandlerFunc, opts ...RouteOption) {
+	m.HandleMethod("GET", pattern, fn, opts...)
+}
+
+func (m *Mux) Post(pattern string, fn http.HandlerFunc, opts ...RouteOption) {
+	m.HandleMethod("POST", pattern, fn, opts...)
+}
+
+func (m *Mux) Put(pattern string, fn http.HandlerFunc, opts ...RouteOption) {
+	m.HandleMethod("PUT", pattern, fn, opts...)
+}
+
+func (m *Mux) Patch(pattern string, fn http.HandlerFunc, opts ...RouteOption) {
+	m.HandleMethod("PATCH", pattern, fn, opts...)
+}
+
+func (m *Mux) Delete(pattern string, fn http.HandlerFunc, opts ...RouteOption) {
+	m.HandleMethod("DELETE", pattern, fn, opts...)
 }
 
 func (m *Mux) HandleStaticFile(pattern, filename string) {
@@ -52,24 +241,100 @@ func (m *Mux) HandleStaticFile(pattern, filename string) {
 
 // Serve a directory as a static file server.
 // e.g. mux.HandleStaticDir("/assets/", "./assets")
+// The root prefix "/" serves the directory for the paths no other route matches.
 func (m *Mux) HandleStaticDir(prefix, dir string) {
-	m.Handle(prefix, http.StripPrefix(prefix, http.FileServer(http.Dir(dir))))
+	if strings.TrimRight(strings.TrimSpace(prefix), "/") == "" {
+		fs := http.FileServer(http.Dir(dir))
+		m.Handle("/", fs)
+		m.Handle("/{path...}", fs)
+		return
+	}
+	m.Mount(prefix, http.FileServer(http.Dir(dir)))
+}
+
+// Mount the handler at the prefix, it serves all the paths under the prefix
+// with the prefix stripped. The prefix may contain route variables, which are
+// visible to the handler. If the handler is a `*Mux`, the variables are merged
+// into the ones it matches.
+// e.g. mux.Mount("/tenants/{tenant}/blog", blogMux)
+func (m *Mux) Mount(prefix string, handler http.Handler, opts ...RouteOption) {
+	prefix = strings.TrimRight(strings.TrimSpace(prefix), "/")
+	if prefix == "" {
+		panic(fmt.Errorf("mount at the root, use NotFoundHandler instead"))
+	}
+	m.Handle(prefix+"/", m.mountHandler(prefix, handler), opts...)
+}
+
+// Serve the handler with the prefix stripped from the path, and the version
+// prefix before it, if any, see `Versioning.PathPrefix`.
+func (m *Mux) mountHandler(prefix string, h http.Handler) http.Handler {
+	depth := strings.Count(prefix, "/")
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		p := r.URL.Path
+		if m.Versioning != nil {
+			p = m.Versioning.trimPath(p)
+		}
+		// Strip the same number of segments as the prefix.
+		for i := 0; i < depth && p != ""; i++ {
+			if j := strings.Index(p[1:], "/"); j >= 0 {
+				p = p[j+1:]
+			} else {
+				p = ""
+			}
+		}
+		if p == "" {
+			p = "/"
+		}
+
+		rc := *routeFrom(r.Context())
+		rc.mount += prefix
+
+		r2 := new(http.Request)
+		*r2 = *r
+		r2.URL = new(url.URL)
+		*r2.URL = *r.URL
+		r2.URL.Path = p
+		r2.URL.RawPath = ""
+		h.ServeHTTP(rw, r2.WithContext(context.WithValue(r.Context(), routeKey, &rc)))
+	})
 }
 
 func (m *Mux) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
-	h, rvs := m.Handler(r)
+	r, h, rc := m.route(r)
+
+	// Let the middlewares see the matched route.
+	if rc != nil {
+		r = setRoute(r, rc)
+	}
 
-	if rvs != nil && len(rvs) > 0 {
-		setRouteVars(r, rvs)
-		h = context.ClearHandler(h)
+	chain(h, m.middlewares).ServeHTTP(rw, r)
+}
+
+// Find the handler for the request, together with the route variables.
+// NB: Global middlewares added by `Use` are not applied to the handler.
+func (m *Mux) Handler(r *http.Request) (h http.Handler, rvs RouteVariables) {
+	_, h, rc := m.route(r)
+	if rc != nil {
+		rvs = rc.vars
 	}
+	return
+}
 
-	h.ServeHTTP(rw, r)
+// Find the handler for the request like `Handler`, together with the pattern
+// matched, which is "" if no route matched.
+func (m *Mux) HandlerPattern(r *http.Request) (h http.Handler, pattern string) {
+	_, h, rc := m.route(r)
+	if rc != nil {
+		pattern = rc.pattern
+	}
+	return
 }
 
-func (m *Mux) Handler(r *http.Request) (http.Handler, RouteVariables) {
+// See `Handler`, the route matched is nil if none. The request returned carries
+// the API version asked for, see `Versioning`, and is the one to serve.
+func (m *Mux) route(r *http.Request) (*http.Request, http.Handler, *routeContext) {
 	if r.RequestURI == "*" {
-		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		return r, http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
 			if r.ProtoAtLeast(1, 1) {
 				rw.Header().Set("Connection", "close")
 			}
@@ -77,22 +342,130 @@ func (m *Mux) Handler(r *http.Request) (http.Handler, RouteVariables) {
 		}), nil
 	}
 
-	if r.Method == "CONNECT" {
-		np := cleanPath(r.URL.Path)
-		if np != r.URL.Path {
-			url := *r.URL
-			url.Path = np
-			return http.RedirectHandler(url.String(), http.StatusMovedPermanently), nil
+	// The path is decoded, so are the percent-encoded segments, e.g. "%2e%2e".
+	if !m.DisableCleanPath {
+		if np := cleanPath(r.URL.Path); np != r.URL.Path {
+			return r, m.redirect(r, np, http.StatusMovedPermanently), nil
 		}
 	}
 
+	r = m.withAPIVersion(r)
 	np := r.URL.Path // normalized path
+	if m.Versioning != nil {
+		np = m.Versioning.trimPath(np)
+	}
+
+	// Serve "HEAD" through "GET" if no handler bound to "HEAD" explicitly.
+	if r.Method == "HEAD" && !m.DisableAutoHead {
+		if mr := m.match(r, r.Method, np); mr.Handler == nil {
+			h, rc := m.handler(r, "GET", np)
+			return r, headHandler(h), rc
+		}
+	}
+
+	h, rc := m.handler(r, r.Method, np)
+	return r, h, rc
+}
+
+// Match the routes of the host first, and fallback to the host-agnostic ones.
+// The route variables in the host are merged into the ones in the path.
+// NB: The handlers on the way of the host are only used if no route matches
+// the full path, i.e. "/a/b" prefers "/a/b" of any host to "/a/" of the host.
+// So is the path of the host matched but not the method, the methods allowed
+// are the ones of all the routers matched if none has a handler of the method.
+func (m *Mux) match(r *http.Request, method, np string) matchResult {
+	var fallback, notAllowed *matchResult
+	for _, hr := range m.loadHosts() {
+		hostVars := make(RouteVariables)
+		if !hr.match(r.Host, hostVars) {
+			continue
+		}
+		mr := hr.router.MatchRequest(method, np, r)
+		mr.RouteVars = mergeHostVars(mr.RouteVars, hostVars)
+		for i := range mr.HandlersOnTheWay {
+			item := &mr.HandlersOnTheWay[i]
+			item.RouteVars = mergeHostVars(item.RouteVars, hostVars)
+		}
+		if mr.Handler != nil {
+			return mr
+		}
+		if notAllowed == nil && len(mr.AllowedMethods) > 0 {
+			notAllowed = &mr
+		} else if len(mr.AllowedMethods) > 0 {
+			notAllowed.AllowedMethods = mergeMethods(notAllowed.AllowedMethods, mr.AllowedMethods)
+		}
+		if fallback == nil && len(mr.HandlersOnTheWay) > 0 {
+			fallback = &mr
+		}
+	}
+
+	mr := m.router.MatchRequest(method, np, r)
+	if mr.Handler != nil {
+		return mr
+	}
+	if notAllowed != nil {
+		notAllowed.AllowedMethods = mergeMethods(notAllowed.AllowedMethods, mr.AllowedMethods)
+		return *notAllowed
+	}
+	if len(mr.AllowedMethods) == 0 && fallback != nil {
+		return *fallback
+	}
+	return mr
+}
+
+// Append the methods absent from the ones of a, which is copied.
+func mergeMethods(a, b []string) []string {
+	merged := append([]string(nil), a...)
+next:
+	for _, x := range b {
+		for _, y := range a {
+			if x == y {
+				continue next
+			}
+		}
+		merged = append(merged, x)
+	}
+	return merged
+}
+
+// Merge the route variables in the host into the ones in the path, which win.
+func mergeHostVars(vars, hostVars RouteVariables) RouteVariables {
+	if vars == nil {
+		vars = make(RouteVariables, len(hostVars))
+	}
+	for name, value := range hostVars {
+		if _, ok := vars[name]; !ok {
+			vars[name] = value
+		}
+	}
+	return vars
+}
 
-	mr := m.router.Match(np)
+func (m *Mux) handler(r *http.Request, method, np string) (http.Handler, *routeContext) {
+	mr := m.match(r, method, np)
 
 	if mr.Handler != nil {
+		if m.RedirectCanonical && mr.CanonicalPath != "" {
+			// Keep the prefix trimmed by the versioning, if any.
+			prefix := strings.TrimSuffix(strings.TrimSuffix(r.URL.Path, strings.TrimPrefix(np, "/")), "/")
+			return m.redirect(r, prefix+mr.CanonicalPath, http.StatusMovedPermanently), nil
+		}
 		// Found a matched handler.
-		return mr.Handler.(http.Handler), mr.RouteVars
+		return mr.Handler.(http.Handler), mr.route()
+	}
+
+	// The path and the method matched, but the API version not.
+	if len(mr.SupportedVersions) > 0 {
+		return unsupportedVersionHandler(mr.SupportedVersions), &routeContext{pattern: mr.Pattern, vars: mr.RouteVars}
+	}
+
+	// The path matched, but the method not.
+	if len(mr.AllowedMethods) > 0 {
+		allowed := m.allowedMethods(mr.AllowedMethods)
+		if method == "OPTIONS" && !m.DisableAutoOptions {
+			return optionsHandler(allowed), &routeContext{pattern: mr.Pattern, vars: mr.RouteVars}
+		}
+		return methodNotAllowedHandler(allowed), &routeContext{pattern: mr.Pattern, vars: mr.RouteVars}
 	}
 
 	notFoundHandler := m.NotFoundHandler
@@ -100,21 +473,31 @@ func (m *Mux) Handler(r *http.Request) (http.Handler, RouteVariables) {
 		notFoundHandler = http.NotFoundHandler()
 	}
 
+	// "/a/b/" redirects to (or is served by) "/a/b", see `SlashPolicy`.
+	if h, rc := m.noSlashHandler(r, method, np); h != nil {
+		return h, rc
+	}
+
 	// `ssp`, strict slash path.
-	// "/a/b" redirects to "/a/b/". "/a/b/" never redirects to "/a/b".
+	// "/a/b" redirects to (or is served by) "/a/b/", see `SlashPolicy`.
 	ssp := ""
 	if len(mr.HandlersOnTheWay) > 0 {
 		ssp = mr.HandlersOnTheWay[len(mr.HandlersOnTheWay)-1].Path
-		if ssp == np+"/" {
-			return http.RedirectHandler(ssp, 302), nil
+		if ssp == np+"/" && m.SlashPolicy == RedirectToSlash {
+			return m.redirect(r, r.URL.Path+"/", http.StatusFound), nil
 		}
 
 		// Fallback to the most right handler (has "/" suffix) matched on the way.
 		for i := len(mr.HandlersOnTheWay) - 1; i >= 0; i-- {
-			if !strings.HasSuffix(mr.HandlersOnTheWay[i].Path, "/") {
+			item := mr.HandlersOnTheWay[i]
+			if !strings.HasSuffix(item.Path, "/") {
+				continue
+			}
+			if item.Path == np+"/" && m.SlashPolicy != IgnoreSlash {
 				continue
 			}
-			return mr.HandlersOnTheWay[i].Handler.(http.Handler), mr.RouteVars
+			return item.Handler.(http.Handler), &routeContext{pattern: item.Pattern, vars: item.RouteVars,
+				values: item.RouteValues, metadata: item.Metadata}
 		}
 
 		// 404
@@ -125,9 +508,115 @@ func (m *Mux) Handler(r *http.Request) (http.Handler, RouteVariables) {
 	return notFoundHandler, nil
 }
 
+// Complete the methods bound to a route with the ones served automatically.
+func (m *Mux) allowedMethods(methods []string) []string {
+	allowed := append([]string(nil), methods...)
+	has := func(method string) bool {
+		for _, x := range allowed {
+			if x == method {
+				return true
+			}
+		}
+		return false
+	}
+	if !m.DisableAutoHead && has("GET") && !has("HEAD") {
+		allowed = append(allowed, "HEAD")
+	}
+	if !m.DisableAutoOptions && !has("OPTIONS") {
+		allowed = append(allowed, "OPTIONS")
+	}
+	sort.Strings(allowed)
+	return allowed
+}
+
+func optionsHandler(allowed []string) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Allow", strings.Join(allowed, ", "))
+		rw.Header().Set("Content-Length", "0")
+		rw.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// Discard the response body, for "HEAD" requests served by "GET" handlers.
+type headResponseWriter struct {
+	http.ResponseWriter
+}
+
+func (w headResponseWriter) Write(b []byte) (int, error) { return len(b), nil }
+
+func headHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(headResponseWriter{rw}, r)
+	})
+}
+
+func methodNotAllowedHandler(allowed []string) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Allow", strings.Join(allowed, ", "))
+		http.Error(rw, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	})
+}
+
 func (m *Mux) GetInternalRouter() *Router { return m.router }
 
-func (m *Mux) DumpRouter() string { return m.router.DumpTree() }
+// Get the router of the host pattern, or the host-agnostic one if it's "".
+// Unlike `hostRouter`, returns an error if not found.
+func (m *Mux) routerOf(host string) (*Router, error) {
+	if host == "" {
+		return m.router, nil
+	}
+	host = normalizeHostPattern(host)
+	for _, hr := range m.loadHosts() {
+		if hr.pattern == host {
+			return hr.router, nil
+		}
+	}
+	return nil, fmt.Errorf("host %q not found", host)
+}
+
+// Get the router of the host pattern, create one if not found.
+func (m *Mux) hostRouter(pattern string) *Router {
+	pattern = normalizeHostPattern(pattern)
+	m.hostsMutex.Lock()
+	defer m.hostsMutex.Unlock()
+	hosts := m.loadHosts()
+	for _, hr := range hosts {
+		if hr.pattern == pattern {
+			return hr.router
+		}
+	}
+
+	regex, err := compileHostPattern(pattern)
+	if err != nil {
+		panic(err)
+	}
+	hr := &hostRouter{pattern: pattern, regex: regex, router: NewRouter(m.routerOptions...), port: hostPatternHasPort(pattern)}
+	hosts = append(hosts[:len(hosts):len(hosts)], hr)
+	sort.Sort(byHostPriority(hosts))
+	m.hosts.Store(hosts)
+	return hr.router
+}
+
+// Get the host routers, which never change once loaded. Safe to call while
+// the routes are changed.
+func (m *Mux) loadHosts() []*hostRouter {
+	hosts, _ := m.hosts.Load().([]*hostRouter)
+	return hosts
+}
+
+// Dump the route trees, the host-agnostic one first, then one per host.
+func (m *Mux) DumpRouter() string {
+	hosts := m.loadHosts()
+	if len(hosts) == 0 {
+		return m.router.DumpTree()
+	}
+	buf := bytes.NewBufferString("* (any host)\n")
+	buf.WriteString(m.router.DumpTree())
+	for _, hr := range hosts {
+		fmt.Fprintf(buf, "%s\n%s", hr.pattern, hr.router.DumpTree())
+	}
+	return buf.String()
+}
 
 func cleanPath(p string) string {
 	if p == "" {
@@ -0,0 +1,826 @@
+package mux
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func textHandler(text string) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		io.WriteString(rw, text)
+	}
+}
+
+func serve(m http.Handler, method, target string) *httptest.ResponseRecorder {
+	rw := httptest.NewRecorder()
+	m.ServeHTTP(rw, httptest.NewRequest(method, target, nil))
+	return rw
+}
+
+func TestMethodRouting(t *testing.T) {
+	m := NewMux()
+	m.Get("/users/{id}\\d+", textHandler("get user"))
+	m.Post("/users/{id}\\d+", textHandler("post user"))
+	m.Handle("/files", textHandler("any files"))
+	m.Delete("/files", textHandler("delete files"))
+
+	cases := []struct {
+		method, target string
+		code           int
+		body, allow    string
+	}{
+		{"GET", "/users/12", 200, "get user", ""},
+		{"POST", "/users/12", 200, "post user", ""},
+		{"PUT", "/users/12", 405, "", "GET, HEAD, OPTIONS, POST"},
+		{"GET", "/users/abc", 404, "", ""},
+		{"GET", "/files", 200, "any files", ""},
+		{"DELETE", "/files", 200, "delete files", ""},
+	}
+
+	for _, c := range cases {
+		rw := serve(m, c.method, c.target)
+		if rw.Code != c.code {
+			t.Errorf("%s %s expects status %d, but got %d", c.method, c.target, c.code, rw.Code)
+			continue
+		}
+		if c.code == 200 && rw.Body.String() != c.body {
+			t.Errorf("%s %s expects body %q, but got %q", c.method, c.target, c.body, rw.Body.String())
+		}
+		if allow := rw.Header().Get("Allow"); allow != c.allow {
+			t.Errorf("%s %s expects Allow %q, but got %q", c.method, c.target, c.allow, allow)
+		}
+	}
+}
+
+func TestPatternExistsWithMethods(t *testing.T) {
+	rt := NewRouter()
+	if err := rt.HandleMethod("GET", "/a", textHandler("a")); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.HandleMethod("POST", "/a", textHandler("a")); err != nil {
+		t.Errorf("GET /a and POST /a should not conflict, got %v", err)
+	}
+	if err := rt.HandleMethod("get", "/a", textHandler("a")); err == nil {
+		t.Errorf("GET /a registered twice should conflict")
+	}
+	if !rt.PatternExists("/a") || !rt.PatternExists("/a", "POST") || rt.PatternExists("/a", "PUT") {
+		t.Errorf("PatternExists reports wrong methods of /a")
+	}
+}
+
+func TestAutoHeadAndOptions(t *testing.T) {
+	m := NewMux()
+	m.Get("/a", textHandler("get a"))
+	m.Post("/a", textHandler("post a"))
+	m.Get("/b", textHandler("get b"))
+	m.HandleMethod("HEAD", "/b", textHandler("head b"))
+	m.HandleMethod("OPTIONS", "/b", textHandler("options b"))
+
+	rw := serve(m, "HEAD", "/a")
+	if rw.Code != 200 || rw.Body.Len() != 0 {
+		t.Errorf("HEAD /a expects 200 without body, but got %d %q", rw.Code, rw.Body.String())
+	}
+
+	rw = serve(m, "OPTIONS", "/a")
+	if allow := rw.Header().Get("Allow"); rw.Code != 204 || allow != "GET, HEAD, OPTIONS, POST" {
+		t.Errorf("OPTIONS /a expects 204 with all methods allowed, but got %d %q", rw.Code, allow)
+	}
+
+	rw = serve(m, "PUT", "/a")
+	if allow := rw.Header().Get("Allow"); rw.Code != 405 || allow != "GET, HEAD, OPTIONS, POST" {
+		t.Errorf("PUT /a expects 405 with all methods allowed, but got %d %q", rw.Code, allow)
+	}
+
+	// Overridden per route.
+	if rw = serve(m, "HEAD", "/b"); rw.Body.String() != "head b" {
+		t.Errorf("HEAD /b expects the bound handler, but got %q", rw.Body.String())
+	}
+	if rw = serve(m, "OPTIONS", "/b"); rw.Body.String() != "options b" {
+		t.Errorf("OPTIONS /b expects the bound handler, but got %q", rw.Body.String())
+	}
+
+	m.DisableAutoHead, m.DisableAutoOptions = true, true
+	for _, method := range []string{"HEAD", "OPTIONS"} {
+		if rw = serve(m, method, "/a"); rw.Code != 405 {
+			t.Errorf("%s /a expects 405 when disabled, but got %d", method, rw.Code)
+		}
+	}
+}
+
+func TestRouteVars(t *testing.T) {
+	m := NewMux()
+	m.HandleFunc("/{category}/{file}/{line}\\d+", func(rw http.ResponseWriter, r *http.Request) {
+		// Route variables survive copying the request.
+		r = r.WithContext(r.Context())
+		line, err := RouteVarInt(r, "line")
+		if err != nil {
+			t.Errorf("RouteVarInt(line) got error %v", err)
+		}
+		fmt.Fprintf(rw, "%s %s %d", RouteVar(r, "category"), RouteVars(r)["file"], line)
+	})
+
+	if rw := serve(m, "GET", "/golang/main.go/13"); rw.Body.String() != "golang main.go 13" {
+		t.Errorf("expects route variables golang main.go 13, but got %q", rw.Body.String())
+	}
+
+	r := httptest.NewRequest("GET", "/golang/main.go/13", nil)
+	if h, rvs := m.Handler(r); h == nil || rvs["line"] != "13" {
+		t.Errorf("Handler expects route variables line=13, but got %v", rvs)
+	}
+	if _, pattern := m.HandlerPattern(r); pattern != "/{category}/{file}/{line}\\d+" {
+		t.Errorf("HandlerPattern expects the pattern matched, but got %q", pattern)
+	}
+	if _, pattern := m.HandlerPattern(httptest.NewRequest("GET", "/a/b", nil)); pattern != "" {
+		t.Errorf("HandlerPattern expects no pattern matched, but got %q", pattern)
+	}
+}
+
+func TestMiddlewares(t *testing.T) {
+	trace := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				rw.Header().Add("X-Trace", fmt.Sprintf("%s(%s %s)", name, RoutePattern(r), RouteVar(r, "id")))
+				next.ServeHTTP(rw, r)
+			})
+		}
+	}
+
+	m := NewMux()
+	m.Use(trace("g1"), trace("g2"))
+	m.Get("/users/{id}", textHandler("user"), WithMiddleware(trace("r1"), trace("r2")))
+	m.Get("/a/", textHandler("a"))
+
+	expects := map[string]string{
+		"/users/7": "g1(/users/{id} 7) g2(/users/{id} 7) r1(/users/{id} 7) r2(/users/{id} 7)",
+		"/nothing": "g1( ) g2( )",
+		"/a":       "g1( ) g2( )",
+	}
+	for target, tr := range expects {
+		rw := serve(m, "GET", target)
+		if got := strings.Join(rw.Header()["X-Trace"], " "); got != tr {
+			t.Errorf("GET %s expects middlewares %q, but got %q", target, tr, got)
+		}
+	}
+}
+
+func TestGroupAndMount(t *testing.T) {
+	tag := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				rw.Header().Add("X-Trace", name)
+				next.ServeHTTP(rw, r)
+			})
+		}
+	}
+	echo := func(rw http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(rw, "%s %s %v", r.URL.Path, RoutePattern(r), RouteVars(r))
+	}
+
+	blog := NewMux()
+	blog.Get("/posts/{id}", echo)
+
+	m := NewMux()
+	api := m.Group("/api/v1/", WithMiddleware(tag("api")))
+	api.Get("/users/{id}\\d+", echo)
+	api.Group("/tenants/{tenant}").Mount("/blog", blog)
+	m.Mount("/raw", http.HandlerFunc(echo))
+
+	cases := []struct {
+		target, body, trace string
+	}{
+		{"/api/v1/users/7", "/api/v1/users/7 /api/v1/users/{id}\\d+ map[id:7]", "api"},
+		{"/api/v1/tenants/acme/blog/posts/3", "/posts/3 /api/v1/tenants/{tenant}/blog/posts/{id} map[id:3 tenant:acme]", "api"},
+		{"/raw/a/b", "/a/b /raw/ map[]", ""},
+	}
+	for _, c := range cases {
+		rw := serve(m, "GET", c.target)
+		if rw.Body.String() != c.body {
+			t.Errorf("GET %s expects %q, but got %q", c.target, c.body, rw.Body.String())
+		}
+		if trace := rw.Header().Get("X-Trace"); trace != c.trace {
+			t.Errorf("GET %s expects middleware %q, but got %q", c.target, c.trace, trace)
+		}
+	}
+}
+
+func TestURL(t *testing.T) {
+	m := NewMux()
+	m.Get("/users/{id}\\d+", textHandler("user"), WithName("user.show"))
+	m.Group("/blog").Get("/{year}\\d{4}/{slug}", textHandler("post"), WithName("post.show"))
+	m.Get("/img/{w:\\d+}x{h:\\d+}.png", textHandler("img"), WithName("img"))
+	m.Get("/list/{page?}\\d+", textHandler("list"), WithName("list"))
+
+	expects := []struct {
+		name  string
+		pairs []string
+		url   string
+	}{
+		{"user.show", []string{"id", "42"}, "/users/42"},
+		{"user.show", []string{"id", "abc"}, ""},
+		{"user.show", nil, ""},
+		{"post.show", []string{"year", "2014", "slug", "hello world"}, "/blog/2014/hello%20world"},
+		{"img", []string{"w", "100", "h", "200"}, "/img/100x200.png"},
+		{"img", []string{"w", "100", "h", "big"}, ""},
+		{"list", nil, "/list"},
+		{"list", []string{"page", "2"}, "/list/2"},
+		{"nothing", nil, ""},
+	}
+	for _, c := range expects {
+		url, err := m.URL(c.name, c.pairs...)
+		if url != c.url || (url == "") != (err != nil) {
+			t.Errorf("URL(%q, %q) expects %q, but got %q, %v", c.name, c.pairs, c.url, url, err)
+		}
+	}
+}
+
+func TestHandleStaticDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, prefix := range []string{"/", "/assets/"} {
+		m := NewMux()
+		m.Get("/api/ping", textHandler("pong"))
+		m.HandleStaticDir(prefix, dir)
+		if rw := serve(m, "GET", strings.TrimSuffix(prefix, "/")+"/a.txt"); rw.Code != 200 || rw.Body.String() != "a" {
+			t.Errorf("HandleStaticDir(%q) expects to serve a.txt, but got %d %q", prefix, rw.Code, rw.Body.String())
+		}
+		if rw := serve(m, "GET", "/api/ping"); rw.Body.String() != "pong" {
+			t.Errorf("HandleStaticDir(%q) expects the other routes served, but got %q", prefix, rw.Body.String())
+		}
+	}
+}
+
+func TestStrictSlashAndFallback(t *testing.T) {
+	m := NewMux()
+	m.Get("/a/", textHandler("a/"))
+	m.Get("/a/b/c", textHandler("a/b/c"))
+	m.Get("/files/{path...}", textHandler("files"))
+	m.Get("/u/{a}/", func(rw http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(rw, "u/ %v", RouteVars(r))
+	})
+	m.Get("/u/{a}/{b}\\d+", textHandler("u/b"))
+
+	cases := []struct {
+		target   string
+		code     int
+		body     string
+		location string
+	}{
+		{"/a", 302, "", "/a/"},
+		{"/a/", 200, "a/", ""},
+		{"/a/x/y", 200, "a/", ""},
+		{"/a/b", 200, "a/", ""},
+		{"/a/b/c", 200, "a/b/c", ""},
+		{"/files", 302, "", "/files/"},
+		{"/files/x/y", 200, "files", ""},
+		{"/b", 404, "", ""},
+		// Only the variables of the fallback pattern.
+		{"/u/x/12/z", 200, "u/ map[a:x]", ""},
+	}
+	for _, c := range cases {
+		rw := serve(m, "GET", c.target)
+		if rw.Code != c.code || (c.body != "" && rw.Body.String() != c.body) || rw.Header().Get("Location") != c.location {
+			t.Errorf("GET %s expects %d %q %q, but got %d %q %q", c.target, c.code, c.body, c.location,
+				rw.Code, rw.Body.String(), rw.Header().Get("Location"))
+		}
+	}
+}
+
+func TestHostRouting(t *testing.T) {
+	echo := func(name string) http.HandlerFunc {
+		return func(rw http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(rw, "%s %v", name, RouteVars(r))
+		}
+	}
+
+	m := NewMux()
+	m.Get("/", echo("home"))
+	m.Get("/users/{id}", echo("users"))
+	m.Get("/", echo("tenant home"), WithHost("{tenant}.example.com"))
+	m.Get("/", echo("www home"), WithHost("www.example.com"))
+	m.Group("/api", WithHost("API.example.com:8080")).Get("/users/{id}", echo("api users"))
+	m.Get("/status", echo("region status"), WithHost("api.{region:[a-z]{2}}.example.com"))
+	m.Get("/me", echo("tenant me"), WithHost("{tenantID}.Example.com"))
+
+	expects := map[string]string{
+		"http://example.com/":                     "home map[]",
+		"http://acme.example.com/":                "tenant home map[tenant:acme]",
+		"http://acme.example.com:8000/":           "tenant home map[tenant:acme]",
+		"http://www.example.com/":                 "www home map[]",
+		"http://acme.example.com/users/1":         "users map[id:1]",
+		"http://api.example.com:8080/api/users/1": "api users map[id:1]",
+		"http://a.b.example.com/":                 "home map[]",
+		"http://api.eu.example.com/status":        "region status map[region:eu]",
+		"http://api.eu.example.com:8080/status":   "region status map[region:eu]",
+		"http://acme.example.com/me":              "tenant me map[tenantID:acme]",
+	}
+	for target, body := range expects {
+		if rw := serve(m, "GET", target); rw.Body.String() != body {
+			t.Errorf("GET %s expects %q, but got %q", target, body, rw.Body.String())
+		}
+	}
+
+	// The method not bound on the host falls back to the host-agnostic routes.
+	m.Get("/a", echo("api a"), WithHost("api.example.com"))
+	m.Post("/a", echo("post a"))
+	m.Put("/a", echo("put api a"), WithHost("{sub}.example.com"))
+	if rw := serve(m, "POST", "http://api.example.com/a"); rw.Body.String() != "post a map[]" {
+		t.Errorf("POST api.example.com/a expects the host-agnostic route, but got %d %q", rw.Code, rw.Body.String())
+	}
+	rw := serve(m, "DELETE", "http://api.example.com/a")
+	if allow := rw.Header().Get("Allow"); rw.Code != 405 || allow != "GET, HEAD, OPTIONS, POST, PUT" {
+		t.Errorf("DELETE api.example.com/a expects 405 allowing the methods of all, but got %d %q", rw.Code, allow)
+	}
+}
+
+func TestHostRoutingConcurrentUpdate(t *testing.T) {
+	m := NewMux()
+	m.Get("/", textHandler("home"))
+
+	done := make(chan bool)
+	go func() {
+		defer close(done)
+		for i := 0; i < 50; i++ {
+			m.Get("/", textHandler("tenant"), WithHost(fmt.Sprintf("t%d.{region}.example.com", i)))
+		}
+	}()
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		if rw := serve(m, "GET", "http://example.com/"); rw.Body.String() != "home" {
+			t.Fatalf("expects home while updating, but got %q", rw.Body.String())
+		}
+	}
+	if rw := serve(m, "GET", "http://t49.eu.example.com/"); rw.Body.String() != "tenant" {
+		t.Errorf("expects the host route, but got %q", rw.Body.String())
+	}
+}
+
+func TestMatchers(t *testing.T) {
+	m := NewMux()
+	m.Get("/report", textHandler("html"))
+	m.Get("/report", textHandler("csv"), WithMatchers(MatchQuery("format", "csv")))
+	m.Get("/report", textHandler("keyed"), WithMatchers(MatchHeader("X-Api-Key", "")))
+	m.Post("/upload", textHandler("json"), WithMatchers(MatchContentType("application/json")))
+	m.Post("/upload", textHandler("text"), WithMatchers(MatchContentType("text/*")))
+	m.Handle("/export", textHandler("any"))
+	m.Get("/export", textHandler("csv"), WithMatchers(MatchQuery("format", "csv")))
+
+	cases := []struct {
+		method, target, header, value string
+		code                          int
+		body                          string
+	}{
+		{"GET", "/report", "", "", 200, "html"},
+		{"GET", "/report?format=csv", "", "", 200, "csv"},
+		{"GET", "/report", "X-Api-Key", "secret", 200, "keyed"},
+		{"GET", "/report?format=csv", "X-Api-Key", "secret", 200, "csv"},
+		{"POST", "/upload", "Content-Type", "application/json; charset=utf-8", 200, "json"},
+		{"POST", "/upload", "Content-Type", "text/csv", 200, "text"},
+		{"POST", "/upload", "Content-Type", "image/png", 404, ""},
+		{"PUT", "/upload", "Content-Type", "text/csv", 405, ""},
+		{"GET", "/export?format=csv", "", "", 200, "csv"},
+		{"GET", "/export", "", "", 200, "any"},
+		{"POST", "/export", "", "", 200, "any"},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest(c.method, c.target, nil)
+		if c.header != "" {
+			r.Header.Set(c.header, c.value)
+		}
+		rw := httptest.NewRecorder()
+		m.ServeHTTP(rw, r)
+		if rw.Code != c.code || (c.code == 200 && rw.Body.String() != c.body) {
+			t.Errorf("%s %s with %s %q expects %d %q, but got %d %q", c.method, c.target, c.header, c.value,
+				c.code, c.body, rw.Code, rw.Body.String())
+		}
+	}
+}
+
+func TestAPIVersioning(t *testing.T) {
+	m := NewMux()
+	m.Versioning = &Versioning{PathPrefix: true, AcceptParam: "version", Header: "Api-Version"}
+	m.Get("/users", textHandler("users v1"), WithVersion("1.0", "1.9"))
+	m.Get("/users", textHandler("users v2"), WithVersion("2.0", ""))
+	m.Get("/ping", textHandler("pong"))
+
+	cases := []struct {
+		target, header, value string
+		code                  int
+		body                  string
+	}{
+		{"/users", "", "", 200, "users v2"},
+		{"/v1/users", "", "", 200, "users v1"},
+		{"/v1.5/users", "", "", 200, "users v1"},
+		{"/v2.1/users", "", "", 200, "users v2"},
+		{"/users", "Accept", "application/json; version=1.2", 200, "users v1"},
+		{"/users", "Api-Version", "v2", 200, "users v2"},
+		{"/v1/users", "Api-Version", "2.0", 200, "users v1"},
+		{"/v0.9/users", "", "", 400, ""},
+		{"/users", "Accept", "application/json; version=0.1", 406, ""},
+		{"/v3/ping", "", "", 200, "pong"},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest("GET", c.target, nil)
+		if c.header != "" {
+			r.Header.Set(c.header, c.value)
+		}
+		rw := httptest.NewRecorder()
+		m.ServeHTTP(rw, r)
+		if rw.Code != c.code || (c.code == 200 && rw.Body.String() != c.body) {
+			t.Errorf("GET %s with %s %q expects %d %q, but got %d %q", c.target, c.header, c.value,
+				c.code, c.body, rw.Code, rw.Body.String())
+		}
+		if c.code != 200 && !strings.Contains(rw.Body.String(), `"supported_versions":["1.0-1.9",">=2.0"]`) {
+			t.Errorf("GET %s expects the supported versions, but got %q", c.target, rw.Body.String())
+		}
+	}
+
+	if err := m.router.HandleMethod("GET", "/users", textHandler("dup"), WithVersion("1.5", "2.5")); err == nil {
+		t.Error("overlapping versions expects a conflict")
+	}
+
+	// The version prefix is stripped together with the mount one.
+	blog := NewMux()
+	blog.Get("/posts", func(rw http.ResponseWriter, r *http.Request) {
+		v, _ := APIVersion(r)
+		fmt.Fprintf(rw, "%s %s", r.URL.Path, v)
+	})
+	m.Mount("/blog", blog)
+	for target, body := range map[string]string{"/v2/blog/posts": "/posts 2.0", "/blog/posts": "/posts 0.0"} {
+		if rw := serve(m, "GET", target); rw.Code != 200 || rw.Body.String() != body {
+			t.Errorf("GET %s expects %q, but got %d %q", target, body, rw.Code, rw.Body.String())
+		}
+	}
+}
+
+func TestRemoveAndReplace(t *testing.T) {
+	m := NewMux()
+	m.Get("/users", textHandler("users"), WithName("users"))
+	m.Post("/users", textHandler("create user"))
+	m.Get("/users", textHandler("admin users"), WithHost("admin.example.com"))
+
+	if err := m.ReplaceMethod("GET", "/users", textHandler("users v2")); err != nil {
+		t.Fatal(err)
+	}
+	if rw := serve(m, "GET", "/users"); rw.Body.String() != "users v2" {
+		t.Errorf("GET /users expects to be replaced, but got %q", rw.Body.String())
+	}
+
+	if err := m.RemoveMethod("POST", "/users"); err != nil {
+		t.Fatal(err)
+	}
+	if rw := serve(m, "POST", "/users"); rw.Code != 405 {
+		t.Errorf("POST /users expects 405 after removed, but got %d", rw.Code)
+	}
+	if _, err := m.URL("users"); err != nil {
+		t.Errorf("route name expects to be kept, but got %v", err)
+	}
+
+	if err := m.Remove("/users"); err != nil {
+		t.Fatal(err)
+	}
+	if rw := serve(m, "GET", "/users"); rw.Code != 404 {
+		t.Errorf("GET /users expects 404 after removed, but got %d", rw.Code)
+	}
+	if _, err := m.URL("users"); err == nil {
+		t.Errorf("route name expects to be removed")
+	}
+
+	r := httptest.NewRequest("GET", "/users", nil)
+	r.Host = "admin.example.com"
+	rw := httptest.NewRecorder()
+	m.ServeHTTP(rw, r)
+	if rw.Body.String() != "admin users" {
+		t.Errorf("route of the host expects to be kept, but got %q", rw.Body.String())
+	}
+	if err := m.Remove("/users", WithHost("admin.example.com")); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Remove("/users", WithHost("www.example.com")); err == nil {
+		t.Errorf("removing from an unknown host expects an error")
+	}
+
+	// Only the names of the host are removed.
+	m.Get("/a", textHandler("a"), WithName("a"))
+	m.Get("/a", textHandler("admin a"), WithName("admin.a"), WithHost("Admin.example.com"))
+	if err := m.Remove("/a", WithHost("admin.example.com")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.URL("a"); err != nil {
+		t.Errorf("route name of the host-agnostic route expects to be kept, but got %v", err)
+	}
+	if _, err := m.URL("admin.a"); err == nil {
+		t.Errorf("route name of the host expects to be removed")
+	}
+}
+
+func TestExplainMiddleware(t *testing.T) {
+	m := NewMux()
+	m.Use(ExplainMiddleware(m))
+	m.Get("/users/{id}\\d+", textHandler("user"))
+	m.Get("/", textHandler("home"), WithHost("api.example.com"))
+
+	rw := serve(m, "GET", "/users/12")
+	if got := rw.Header().Get("X-Route-Explain"); !strings.HasPrefix(got, `matched "/users/{id}\\d+"`) {
+		t.Errorf("unexpected explanation %q", got)
+	}
+	r := httptest.NewRequest("GET", "http://api.example.com/", nil)
+	rw = httptest.NewRecorder()
+	m.ServeHTTP(rw, r)
+	if got := rw.Header().Get("X-Route-Explain"); !strings.HasSuffix(got, "host api.example.com") {
+		t.Errorf("unexpected explanation %q", got)
+	}
+}
+
+func TestSlashPolicy(t *testing.T) {
+	newMux := func(policy SlashPolicy) *Mux {
+		m := NewMux()
+		m.SlashPolicy = policy
+		m.Get("/a/", textHandler("a/"))
+		m.Get("/b", textHandler("b"))
+		m.Get("/b/{name}/", textHandler("b/name/"))
+		return m
+	}
+	cases := []struct {
+		policy   SlashPolicy
+		target   string
+		code     int
+		body     string
+		location string
+	}{
+		{RedirectToSlash, "/a?x=1", 302, "", "/a/?x=1"},
+		{RedirectToSlash, "/b/", 404, "", ""},
+		{RedirectToNoSlash, "/a", 404, "", ""},
+		{RedirectToNoSlash, "/b/?x=1", 302, "", "/b?x=1"},
+		{RedirectToNoSlash, "/b/x/", 200, "b/name/", ""},
+		{StrictSlash, "/a", 404, "", ""},
+		{StrictSlash, "/b/", 404, "", ""},
+		{StrictSlash, "/b/x", 404, "", ""},
+		{IgnoreSlash, "/a", 200, "a/", ""},
+		{IgnoreSlash, "/b/", 200, "b", ""},
+		{IgnoreSlash, "/b/x", 200, "b/name/", ""},
+	}
+	for _, c := range cases {
+		rw := serve(newMux(c.policy), "GET", c.target)
+		if rw.Code != c.code || (c.body != "" && rw.Body.String() != c.body) || rw.Header().Get("Location") != c.location {
+			t.Errorf("policy %d: GET %s expects %d %q %q, but got %d %q %q", c.policy, c.target, c.code, c.body, c.location,
+				rw.Code, rw.Body.String(), rw.Header().Get("Location"))
+		}
+	}
+
+	m := newMux(RedirectToSlash)
+	m.RedirectCode = http.StatusPermanentRedirect
+	if rw := serve(m, "GET", "/a"); rw.Code != 308 || rw.Header().Get("Location") != "/a/" {
+		t.Errorf("expects 308 to /a/, but got %d %q", rw.Code, rw.Header().Get("Location"))
+	}
+}
+
+func TestCleanPath(t *testing.T) {
+	m := NewMux()
+	m.Get("/a/b", textHandler("a/b"))
+	m.Post("/a/b", textHandler("post a/b"))
+
+	cases := []struct {
+		method   string
+		target   string
+		code     int
+		location string
+	}{
+		{"GET", "/a/b", 200, ""},
+		{"GET", "/a//b", 301, "/a/b"},
+		{"GET", "/a/c/../b?x=1", 301, "/a/b?x=1"},
+		{"GET", "/a/%2e%2e/a/./b", 301, "/a/b"},
+		{"POST", "/x/../a/b//", 301, "/a/b/"},
+	}
+	for _, c := range cases {
+		rw := serve(m, c.method, c.target)
+		if rw.Code != c.code || rw.Header().Get("Location") != c.location {
+			t.Errorf("%s %s expects %d %q, but got %d %q", c.method, c.target, c.code, c.location, rw.Code, rw.Header().Get("Location"))
+		}
+	}
+
+	m.RedirectCode = http.StatusTemporaryRedirect
+	if rw := serve(m, "POST", "/a//b"); rw.Code != 307 {
+		t.Errorf("expects 307, but got %d", rw.Code)
+	}
+	m.DisableCleanPath = true
+	if rw := serve(m, "GET", "/a//b"); rw.Code != 404 {
+		t.Errorf("expects 404 without cleaning, but got %d", rw.Code)
+	}
+}
+
+func TestRedirectCanonical(t *testing.T) {
+	m := NewMux(IgnoreCase())
+	m.Get("/Users/{name}", textHandler("user"))
+	m.Get("/", textHandler("home"), WithHost("api.example.com"))
+	m.Get("/About", textHandler("about"), WithHost("api.example.com"))
+
+	if rw := serve(m, "GET", "/users/Ggicci"); rw.Code != 200 || rw.Body.String() != "user" {
+		t.Errorf("expects 200 user, but got %d %q", rw.Code, rw.Body.String())
+	}
+	r := httptest.NewRequest("GET", "http://api.example.com/about", nil)
+	rw := httptest.NewRecorder()
+	m.ServeHTTP(rw, r)
+	if rw.Body.String() != "about" {
+		t.Errorf("expects host routes to ignore case, but got %d %q", rw.Code, rw.Body.String())
+	}
+
+	m.RedirectCanonical = true
+	rw = serve(m, "GET", "/USERS/Ggicci?x=1")
+	if rw.Code != 301 || rw.Header().Get("Location") != "/Users/Ggicci?x=1" {
+		t.Errorf("expects 301 to the registered spelling, but got %d %q", rw.Code, rw.Header().Get("Location"))
+	}
+	if rw := serve(m, "GET", "/Users/Ggicci"); rw.Code != 200 {
+		t.Errorf("expects 200, but got %d", rw.Code)
+	}
+}
+
+func TestRouteValues(t *testing.T) {
+	m := NewMux()
+	m.Get("/users/{id:int}/logs/{day:date}", func(rw http.ResponseWriter, r *http.Request) {
+		id, _ := RouteValueInt(r, "id")
+		day, _ := RouteValueTime(r, "day")
+		fmt.Fprintf(rw, "%d %s", id+1, day.Format("Jan 2"))
+	}, WithName("logs"))
+
+	if rw := serve(m, "GET", "/users/41/logs/2024-03-01"); rw.Body.String() != "42 Mar 1" {
+		t.Errorf("unexpected body %q", rw.Body.String())
+	}
+	if rw := serve(m, "GET", "/users/41/logs/2024-13-01"); rw.Code != 404 {
+		t.Errorf("expects 404 of an invalid date, but got %d", rw.Code)
+	}
+	if _, err := m.URL("logs", "id", "1", "day", "2024-13-01"); err == nil {
+		t.Errorf("expects an error of an invalid date")
+	}
+}
+
+func TestRouteMetadata(t *testing.T) {
+	scopes := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			if s, ok := RouteMetadata(r, "scope").(string); ok && r.Header.Get("X-Scope") != s {
+				http.Error(rw, "forbidden", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(rw, r)
+		})
+	}
+	echo := func(rw http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(rw, "%v", RouteMetadataFrom(r.Context()))
+	}
+
+	blog := NewMux()
+	blog.Get("/posts", echo, WithMetadata("owner", "blog"))
+
+	m := NewMux()
+	m.Use(scopes)
+	admin := m.Group("/admin", WithMetadata("scope", "admin"), WithMetadata("owner", "core"))
+	admin.Get("/users", echo, WithMetadata("owner", "accounts"))
+	m.Get("/public", echo)
+	m.Mount("/blog", blog)
+
+	if rw := serve(m, "GET", "/admin/users"); rw.Code != http.StatusForbidden {
+		t.Errorf("expects 403 without the scope, but got %d", rw.Code)
+	}
+	r := httptest.NewRequest("GET", "/admin/users", nil)
+	r.Header.Set("X-Scope", "admin")
+	rw := httptest.NewRecorder()
+	m.ServeHTTP(rw, r)
+	if got := rw.Body.String(); got != "map[owner:accounts scope:admin]" {
+		t.Errorf("unexpected metadata %q", got)
+	}
+	if rw := serve(m, "GET", "/public"); rw.Body.String() != "map[]" {
+		t.Errorf("expects no metadata, but got %q", rw.Body.String())
+	}
+	if rw := serve(m, "GET", "/blog/posts"); rw.Body.String() != "map[owner:blog]" {
+		t.Errorf("unexpected metadata of the mounted mux %q", rw.Body.String())
+	}
+
+	routes := m.Routes()
+	if md := routes[0].Handlers[0].Metadata; md["scope"] != "admin" || md["owner"] != "accounts" {
+		t.Errorf("unexpected metadata of walk %v", md)
+	}
+}
+
+type openAPIUser struct {
+	ID      int            `json:"id"`
+	Name    string         `json:"name" validate:"required,max=32"`
+	Friends []*openAPIUser `json:"friends,omitempty"`
+}
+
+type openAPIListUsers struct {
+	Team  int    `route:"team"`
+	Page  int    `query:"page" default:"1" validate:"min=1"`
+	Sort  string `query:"sort" validate:"oneof=name date"`
+	Token string `header:"X-Token" validate:"required"`
+}
+
+func TestOpenAPI(t *testing.T) {
+	h := textHandler("")
+	m := NewMux()
+	m.Get("/teams/{team}/users", h, WithName("users"), WithRequest(openAPIListUsers{}),
+		WithResponse(200, []openAPIUser{}), WithMetadata("tags", []string{"users"}), WithMetadata("owner", "accounts"))
+	m.Put("/users/{id:int}", h, WithName("user"), WithResponse(204, nil))
+	m.Delete("/users/{id:int}", h, WithName("user"), WithMetadata("deprecated", "2025-01-01"))
+	m.Get("/files/{name:\\w+}.{ext:slug}", h)
+	m.Get("/exports/{id:int}.{ext:json|xml}", h)
+	m.Get("/archive/{year?}\\d{4}", h)
+	m.Handle("/raw/{path...}", h)
+	m.ServeOpenAPI("/openapi.yaml", OpenAPIInfo{Title: "Test", Version: "1.0"})
+
+	buf := new(strings.Builder)
+	if err := NewOpenAPI(OpenAPIInfo{Title: "Test", Version: "1.0"}, m.Routes()).WriteJSON(buf); err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		OpenAPI    string
+		Paths      map[string]map[string]map[string]interface{}
+		Components struct{ Schemas map[string]interface{} }
+	}
+	if err := json.Unmarshal([]byte(buf.String()), &doc); err != nil {
+		t.Fatalf("invalid JSON %v\n%s", err, buf)
+	}
+	dump := func(v interface{}) string {
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+
+	paths := make([]string, 0)
+	for path, item := range doc.Paths {
+		for method := range item {
+			paths = append(paths, method+" "+path)
+		}
+	}
+	sort.Strings(paths)
+	expected := "[delete /raw/{path} delete /users/{id} get /archive get /archive/{year} get /exports/{id}.{ext} get /files/{name}.{ext} " +
+		"get /raw/{path} get /teams/{team}/users patch /raw/{path} post /raw/{path} put /raw/{path} put /users/{id}]"
+	if fmt.Sprint(paths) != expected {
+		t.Errorf("expects operations %s, but got %v", expected, paths)
+	}
+
+	users := doc.Paths["/teams/{team}/users"]["get"]
+	if got := dump(users["parameters"]); got != `[{"in":"path","name":"team","required":true,"schema":{"type":"integer"}},`+
+		`{"in":"query","name":"page","schema":{"default":1,"minimum":1,"type":"integer"}},`+
+		`{"in":"query","name":"sort","schema":{"enum":["name","date"],"type":"string"}},`+
+		`{"in":"header","name":"X-Token","required":true,"schema":{"type":"string"}}]` {
+		t.Errorf("unexpected parameters %s", got)
+	}
+	if got := dump(users["responses"]); got != `{"200":{"content":{"application/json":{"schema":{"items":{"$ref":"#/components/schemas/openAPIUser"},"type":"array"}}},"description":"OK"}}` {
+		t.Errorf("unexpected responses %s", got)
+	}
+	if users["operationId"] != "users" || users["x-owner"] != "accounts" || dump(users["tags"]) != `["users"]` {
+		t.Errorf("unexpected operation %s", dump(users))
+	}
+	if got := dump(doc.Components.Schemas["openAPIUser"]); got != `{"properties":{"friends":{"items":{"$ref":"#/components/schemas/openAPIUser"},"type":"array"},`+
+		`"id":{"type":"integer"},"name":{"maxLength":32,"type":"string"}},"required":["name"],"type":"object"}` {
+		t.Errorf("unexpected schema %s", got)
+	}
+
+	del := doc.Paths["/users/{id}"]["delete"]
+	if del["operationId"] != "user_delete" || del["deprecated"] != true || del["x-deprecated"] != "2025-01-01" {
+		t.Errorf("unexpected operation %s", dump(del))
+	}
+	if got := dump(doc.Paths["/users/{id}"]["put"]["responses"]); got != `{"204":{"description":"No Content"}}` {
+		t.Errorf("unexpected responses %s", got)
+	}
+	if got := dump(doc.Paths["/files/{name}.{ext}"]["get"]["parameters"]); got != `[{"in":"path","name":"name","required":true,"schema":{"pattern":"^(?:\\w+)$","type":"string"}},`+
+		`{"in":"path","name":"ext","required":true,"schema":{"pattern":"^(?:[a-z0-9]+(?:-[a-z0-9]+)*)$","type":"string"}}]` {
+		t.Errorf("unexpected parameters %s", got)
+	}
+	if got := dump(doc.Paths["/exports/{id}.{ext}"]["get"]["parameters"]); got != `[{"in":"path","name":"id","required":true,"schema":{"type":"integer"}},`+
+		`{"in":"path","name":"ext","required":true,"schema":{"pattern":"^(?:json|xml)$","type":"string"}}]` {
+		t.Errorf("unexpected parameters %s", got)
+	}
+	if got := dump(doc.Paths["/archive/{year}"]["get"]["parameters"]); got != `[{"in":"path","name":"year","required":true,"schema":{"pattern":"^\\d{4}$","type":"string"}}]` {
+		t.Errorf("unexpected parameters %s", got)
+	}
+
+	rw := serve(m, "GET", "/openapi.yaml")
+	yaml := rw.Body.String()
+	for _, line := range []string{
+		"openapi: \"3.1.0\"\n",
+		"info:\n  title: \"Test\"\n",
+		"  \"/users/{id}\":\n    delete:\n      operationId: \"user_delete\"\n",
+		"        - name: \"id\"\n          in: \"path\"\n          required: true\n          schema:\n            type: \"integer\"\n",
+	} {
+		if !strings.Contains(yaml, line) {
+			t.Errorf("expects YAML with %q, but got:\n%s", line, yaml)
+		}
+	}
+	if rw.Header().Get("Content-Type") != "application/yaml" || strings.Contains(yaml, "openapi.yaml") {
+		t.Errorf("unexpected YAML:\n%s", yaml)
+	}
+}
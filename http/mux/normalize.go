@@ -0,0 +1,87 @@
+package mux
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// A router option configures how the router compares the paths. It's fixed
+// once the router is made, see `NewRouter`.
+type RouterOption func(*Router)
+
+// Match the static parts of the patterns regardless of case, e.g. pattern
+// "/Users/{name}" matches "/users/Ggicci" with name "Ggicci". The regexes and
+// the route variables see the path as is.
+func IgnoreCase() RouterOption {
+	return func(rt *Router) { rt.options.ignoreCase = true }
+}
+
+// Normalize the patterns and the paths to Unicode NFC before matching, e.g.
+// "é" written as "e" followed by U+0301 matches "é". The route variables are
+// normalized too.
+func NormalizeUnicode() RouterOption {
+	return func(rt *Router) { rt.options.nfc = true }
+}
+
+// How the paths are compared, see `RouterOption`.
+type pathOptions struct {
+	ignoreCase bool
+	nfc        bool
+}
+
+func (o pathOptions) normalize(s string) string {
+	if o.nfc {
+		return norm.NFC.String(s)
+	}
+	return s
+}
+
+// The key of the path to compare with the static text in the tree, which is
+// indexed as the path, see `foldCase`.
+func (o pathOptions) key(path string) string {
+	if o.ignoreCase {
+		return foldCase(path)
+	}
+	return path
+}
+
+// Split the routes to tokens, the static text is folded if case is ignored.
+func (o pathOptions) tokenize(routes []*route) []token {
+	tokens := tokenize(routes)
+	if o.ignoreCase {
+		for i := range tokens {
+			tokens[i].text = foldCase(tokens[i].text)
+		}
+	}
+	return tokens
+}
+
+// Lower the case of s, except the runes whose lower case are of a different
+// length in UTF-8, e.g. "İ". So the folded string is indexed as the original.
+func foldCase(s string) string {
+	return strings.Map(func(r rune) rune {
+		if l := unicode.ToLower(r); utf8.RuneLen(l) == utf8.RuneLen(r) {
+			return l
+		}
+		return r
+	}, s)
+}
+
+// The path spelled as the static parts of the pattern, the other parts are
+// left as is, e.g. "/Users/Ggicci" of pattern "/users/{name}" is
+// "/users/Ggicci".
+func (l *leaf) canonical(path string) string {
+	parts := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	for i, r := range l.routes {
+		if i >= len(parts) || r.priority == kWildcardPattern {
+			break
+		}
+		if r.priority == kAbsolutePattern {
+			parts[i] = r.pattern
+		}
+	}
+	return "/" + strings.Join(parts, "/")
+}
@@ -0,0 +1,772 @@
+package mux
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The info of the API, see `NewOpenAPI`.
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// An OpenAPI 3.1 document of the routes, see `NewOpenAPI`.
+type OpenAPI struct {
+	OpenAPI    string                     `json:"openapi"`
+	Info       OpenAPIInfo                `json:"info"`
+	Paths      map[string]OpenAPIPathItem `json:"paths"`
+	Components *OpenAPIComponents         `json:"components,omitempty"`
+}
+
+type OpenAPIComponents struct {
+	Schemas map[string]JSONSchema `json:"schemas,omitempty"`
+}
+
+// The operations of a path by the lower case method, e.g. "get".
+type OpenAPIPathItem map[string]*OpenAPIOperation
+
+type OpenAPIOperation struct {
+	OperationID string              `json:"operationId,omitempty"`
+	Summary     string              `json:"summary,omitempty"`
+	Description string              `json:"description,omitempty"`
+	Tags        []string            `json:"tags,omitempty"`
+	Deprecated  bool                `json:"deprecated,omitempty"`
+	Parameters  []*OpenAPIParameter `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody `json:"requestBody,omitempty"`
+	// By the status code, e.g. "200", or "default".
+	Responses map[string]*OpenAPIResponse `json:"responses"`
+	// The "x-" fields, e.g. "x-owner" of the metadata "owner".
+	Extensions map[string]interface{} `json:"-"`
+}
+
+func (op *OpenAPIOperation) MarshalJSON() ([]byte, error) {
+	type operation OpenAPIOperation
+	b, err := json.Marshal((*operation)(op))
+	if err != nil || len(op.Extensions) == 0 {
+		return b, err
+	}
+	ext, err := json.Marshal(op.Extensions)
+	if err != nil {
+		return nil, err
+	}
+	// Join the objects, the fields of the operation are never all empty.
+	return append(append(b[:len(b)-1], ','), ext[1:]...), nil
+}
+
+type OpenAPIParameter struct {
+	Name string `json:"name"`
+	// "path", "query" or "header".
+	In          string     `json:"in"`
+	Description string     `json:"description,omitempty"`
+	Required    bool       `json:"required,omitempty"`
+	Schema      JSONSchema `json:"schema"`
+}
+
+type OpenAPIRequestBody struct {
+	Required bool                        `json:"required,omitempty"`
+	Content  map[string]OpenAPIMediaType `json:"content"`
+}
+
+type OpenAPIMediaType struct {
+	Schema JSONSchema `json:"schema"`
+}
+
+type OpenAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]OpenAPIMediaType `json:"content,omitempty"`
+}
+
+// A JSON Schema, e.g. {"type": "string", "pattern": "^\\d+$"}.
+type JSONSchema map[string]interface{}
+
+// The metadata read by `NewOpenAPI`, the other ones are "x-" fields.
+const (
+	openAPISummary     = "summary"
+	openAPIDescription = "description"
+	openAPITags        = "tags"
+	openAPIDeprecated  = "deprecated"
+	openAPIHidden      = "hidden"
+	openAPIRequest     = "request"
+	openAPIResponses   = "responses"
+)
+
+// Document the request of the route for `NewOpenAPI` by a value of the struct
+// it's bound to, see `request.Bind`. The fields tagged "query" and "header"
+// are the parameters, the "form" and "json" ones are the body. The "validate"
+// tags are the constraints, e.g. "required" and "max=100".
+// e.g. mux.Post("/users", h, WithRequest(CreateUser{}))
+func WithRequest(v interface{}) RouteOption {
+	return WithMetadata(openAPIRequest, v)
+}
+
+// Document the response of the status code for `NewOpenAPI` by a value of the
+// JSON body, nil if no body. Given multiple times, one per code.
+// e.g. mux.Post("/users", h, WithResponse(201, User{}), WithResponse(409, nil))
+func WithResponse(code int, v interface{}) RouteOption {
+	return func(ro *routeOptions) {
+		responses := make(map[int]interface{})
+		if prev, ok := ro.metadata[openAPIResponses].(map[int]interface{}); ok {
+			for c, x := range prev {
+				responses[c] = x
+			}
+		}
+		responses[code] = v
+		WithMetadata(openAPIResponses, responses)(ro)
+	}
+}
+
+// The methods of an operation of a handler bound to any method.
+var openAPIAnyMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE"}
+
+// Generate the OpenAPI 3.1 document of the routes, e.g.
+// NewOpenAPI(info, m.Routes()).WriteYAML(f).
+//
+// Each pattern is a path, the optional parts expand to the shorter paths. The
+// route variables are the path parameters, their regexes are the "pattern"
+// constraints, and the converters the types, e.g. "{id:int}" is an integer.
+// A handler bound to any method is documented as GET, POST, PUT, PATCH and
+// DELETE. The name of a handler is the operation ID, see `WithName`.
+//
+// The metadata "summary", "description" (string), "tags" ([]string) and
+// "deprecated" (true, or a string like the date) document the operation, the
+// route with the metadata "hidden" of true isn't documented. The other
+// metadata are the "x-" fields, e.g. "x-owner" of "owner". See `WithRequest`
+// and `WithResponse` for the bodies, whose named structs are the schemas of
+// the components.
+func NewOpenAPI(info OpenAPIInfo, routes []RouteInfo) *OpenAPI {
+	g := &openAPIGen{names: make(map[reflect.Type]string), schemas: make(map[string]JSONSchema)}
+	doc := &OpenAPI{OpenAPI: "3.1.0", Info: info, Paths: make(map[string]OpenAPIPathItem)}
+	type entry struct {
+		method string
+		op     *OpenAPIOperation
+	}
+	ids := make(map[string][]entry)
+	for _, route := range routes {
+		for _, p := range openAPIPaths(route) {
+			for _, h := range openAPIHandlers(route) {
+				methods := []string{h.Method}
+				if h.Method == "*" {
+					methods = openAPIAnyMethods
+				}
+				for _, method := range methods {
+					item := doc.Paths[p.path]
+					if item == nil {
+						item = make(OpenAPIPathItem)
+						doc.Paths[p.path] = item
+					}
+					// The methods bound explicitly, and the routes of any host
+					// registered first, win.
+					method = strings.ToLower(method)
+					if _, ok := item[method]; ok {
+						continue
+					}
+					op := g.operation(h, route.Host, p.params)
+					item[method] = op
+					if op.OperationID != "" {
+						ids[op.OperationID] = append(ids[op.OperationID], entry{method, op})
+					}
+				}
+			}
+		}
+	}
+	// Keep the operation IDs unique, e.g. "user_get" and "user_put", then
+	// "archive_get" and "archive_get_2" of the optional parts.
+	for _, entries := range ids {
+		if len(entries) < 2 {
+			continue
+		}
+		seen := make(map[string]int)
+		for _, e := range entries {
+			e.op.OperationID += "_" + e.method
+			if seen[e.op.OperationID]++; seen[e.op.OperationID] > 1 {
+				e.op.OperationID += "_" + strconv.Itoa(seen[e.op.OperationID])
+			}
+		}
+	}
+	if len(g.schemas) > 0 {
+		doc.Components = &OpenAPIComponents{Schemas: g.schemas}
+	}
+	return doc
+}
+
+// The first handler of each method, the ones not hidden.
+func openAPIHandlers(route RouteInfo) []HandlerInfo {
+	handlers := make([]HandlerInfo, 0, len(route.Handlers))
+	seen := make(map[string]bool)
+	for _, h := range route.Handlers {
+		if seen[h.Method] {
+			continue
+		}
+		seen[h.Method] = true
+		if hidden, _ := h.Metadata[openAPIHidden].(bool); !hidden {
+			handlers = append(handlers, h)
+		}
+	}
+	// The methods bound explicitly first.
+	sort.SliceStable(handlers, func(i, j int) bool { return handlers[j].Method == "*" && handlers[i].Method != "*" })
+	return handlers
+}
+
+type openAPIPath struct {
+	path   string
+	params []*OpenAPIParameter
+}
+
+// The paths of the pattern, the shortest one first, see `expandOptionalRoutes`.
+func openAPIPaths(route RouteInfo) []openAPIPath {
+	paths := make([]openAPIPath, 0, 1)
+	segments := make([]string, 0, len(route.Parts))
+	params := make([]*OpenAPIParameter, 0)
+	for i, part := range route.Parts {
+		if part.Optional {
+			paths = append(paths, openAPIPath{"/" + strings.Join(segments, "/"), params[:len(params):len(params)]})
+		}
+		segment, ps := openAPISegment(part, i)
+		segments = append(segments, segment)
+		params = append(params, ps...)
+	}
+	return append(paths, openAPIPath{"/" + strings.Join(segments, "/"), params})
+}
+
+// The segment of the path of the part, and its parameters.
+func openAPISegment(part RoutePart, i int) (string, []*OpenAPIParameter) {
+	name := fmt.Sprintf("param%d", i)
+	if len(part.Vars) > 0 && part.Vars[0] != "" {
+		name = part.Vars[0]
+	}
+	param := &OpenAPIParameter{Name: name, In: "path", Required: true, Schema: JSONSchema{"type": "string"}}
+
+	switch part.Kind {
+	case "static":
+		return part.Part, nil
+	case "regex":
+		param.Schema["pattern"] = part.Regex
+	case "wildcard":
+		param.Description = `The rest of the path, "/" included.`
+	case "partial":
+		buf := new(strings.Builder)
+		params := make([]*OpenAPIParameter, 0)
+		for _, piece := range splitPartialRoute(part.Part) {
+			if piece.name == "" {
+				buf.WriteString(piece.text)
+				continue
+			}
+			p := &OpenAPIParameter{Name: piece.name, In: "path", Required: true, Schema: JSONSchema{"type": "string"}}
+			if piece.converter != nil {
+				p.Schema = converterSchema(piece.converter.converter, piece.text)
+			} else if piece.text != "" {
+				p.Schema["pattern"] = "^(?:" + piece.text + ")$"
+			}
+			fmt.Fprintf(buf, "{%s}", piece.name)
+			params = append(params, p)
+		}
+		return buf.String(), params
+	}
+	return "{" + name + "}", []*OpenAPIParameter{param}
+}
+
+// The schema of the route variable by the converter.
+func converterSchema(converter, regex string) JSONSchema {
+	switch converter {
+	case "int":
+		return JSONSchema{"type": "integer"}
+	case "uuid":
+		return JSONSchema{"type": "string", "format": "uuid"}
+	case "date":
+		return JSONSchema{"type": "string", "format": "date"}
+	}
+	return JSONSchema{"type": "string", "pattern": "^(?:" + regex + ")$"}
+}
+
+// Make the schemas of the types, the named structs are the components.
+type openAPIGen struct {
+	names   map[reflect.Type]string
+	schemas map[string]JSONSchema
+}
+
+func (g *openAPIGen) operation(h HandlerInfo, host string, params []*OpenAPIParameter) *OpenAPIOperation {
+	op := &OpenAPIOperation{OperationID: h.Name, Responses: make(map[string]*OpenAPIResponse)}
+	for _, p := range params {
+		param := *p
+		op.Parameters = append(op.Parameters, &param)
+	}
+	for key, value := range h.Metadata {
+		switch key {
+		case openAPISummary:
+			op.Summary, _ = value.(string)
+		case openAPIDescription:
+			op.Description, _ = value.(string)
+		case openAPITags:
+			op.Tags, _ = value.([]string)
+		case openAPIDeprecated:
+			if s, ok := value.(string); ok && s != "" {
+				op.Deprecated = true
+				op.setExtension("x-deprecated", s)
+			} else {
+				op.Deprecated, _ = value.(bool)
+			}
+		case openAPIRequest:
+			g.request(op, reflect.TypeOf(value))
+		case openAPIResponses:
+			responses, _ := value.(map[int]interface{})
+			for code, v := range responses {
+				resp := &OpenAPIResponse{Description: http.StatusText(code)}
+				if v != nil {
+					resp.Content = map[string]OpenAPIMediaType{"application/json": {Schema: g.schema(reflect.TypeOf(v))}}
+				}
+				op.Responses[strconv.Itoa(code)] = resp
+			}
+		case openAPIHidden:
+		default:
+			if _, err := json.Marshal(value); err == nil {
+				op.setExtension("x-"+key, value)
+			}
+		}
+	}
+	if h.Version != "" {
+		op.setExtension("x-api-version", h.Version)
+	}
+	if host != "" {
+		op.setExtension("x-host", host)
+	}
+	if len(op.Responses) == 0 {
+		op.Responses["default"] = &OpenAPIResponse{Description: "The response."}
+	}
+	return op
+}
+
+func (op *OpenAPIOperation) setExtension(key string, value interface{}) {
+	if op.Extensions == nil {
+		op.Extensions = make(map[string]interface{})
+	}
+	op.Extensions[key] = value
+}
+
+// Document the parameters and the body of the request bound to the struct,
+// see `WithRequest`.
+func (g *openAPIGen) request(op *OpenAPIOperation, t reflect.Type) {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return
+	}
+
+	form := JSONSchema{"type": "object", "properties": JSONSchema{}}
+	body := JSONSchema{"type": "object", "properties": JSONSchema{}}
+	structFields(t, func(field reflect.StructField) {
+		validate := field.Tag.Get("validate")
+		for _, in := range []string{"route", "query", "header"} {
+			name := field.Tag.Get(in)
+			if name == "" || name == "-" {
+				continue
+			}
+			schema := paramSchema(field)
+			required := applyValidate(schema, validate)
+			if in == "route" {
+				// The types of the route variables without a regex.
+				for _, p := range op.Parameters {
+					if p.In == "path" && p.Name == name && len(p.Schema) == 1 {
+						p.Schema = schema
+					}
+				}
+				continue
+			}
+			op.Parameters = append(op.Parameters, &OpenAPIParameter{Name: name, In: in, Required: required, Schema: schema})
+		}
+		if name := field.Tag.Get("form"); name != "" && name != "-" {
+			addProperty(form, name, paramSchema(field), validate)
+		}
+		if name := strings.Split(field.Tag.Get("json"), ",")[0]; name != "" && name != "-" {
+			addProperty(body, name, g.schema(field.Type), validate)
+		}
+	})
+
+	content := make(map[string]OpenAPIMediaType)
+	if len(form["properties"].(JSONSchema)) > 0 {
+		content["application/x-www-form-urlencoded"] = OpenAPIMediaType{Schema: form}
+		content["multipart/form-data"] = OpenAPIMediaType{Schema: form}
+	}
+	if len(body["properties"].(JSONSchema)) > 0 {
+		content["application/json"] = OpenAPIMediaType{Schema: body}
+	}
+	if len(content) > 0 {
+		op.RequestBody = &OpenAPIRequestBody{Content: content}
+	}
+}
+
+// Call fn with each exported field of the struct, the ones of the embedded
+// structs without a json name included, as they're flattened by encoding/json.
+func structFields(t reflect.Type, fn func(reflect.StructField)) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && field.Tag.Get("json") == "" {
+			structFields(field.Type, fn)
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		fn(field)
+	}
+}
+
+func addProperty(object JSONSchema, name string, schema JSONSchema, validate string) {
+	object["properties"].(JSONSchema)[name] = schema
+	if applyValidate(schema, validate) {
+		required, _ := object["required"].([]string)
+		object["required"] = append(required, name)
+	}
+}
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	durationType        = reflect.TypeOf(time.Duration(0))
+	jsonMarshalerType   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	schemaName          = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+)
+
+// The schema of the field as a string of the request, e.g. a query parameter.
+func paramSchema(field reflect.StructField) JSONSchema {
+	t := field.Type
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8 {
+		items := paramSchema(reflect.StructField{Type: t.Elem(), Tag: field.Tag})
+		delete(items, "default")
+		schema := JSONSchema{"type": "array", "items": items}
+		if def, ok := field.Tag.Lookup("default"); ok {
+			schema["default"] = strings.Split(def, ",")
+		}
+		return schema
+	}
+
+	var schema JSONSchema
+	switch {
+	case t == timeType && field.Tag.Get("layout") == "2006-01-02":
+		schema = JSONSchema{"type": "string", "format": "date"}
+	case t == timeType && field.Tag.Get("layout") == "":
+		schema = JSONSchema{"type": "string", "format": "date-time"}
+	case t == durationType:
+		schema = JSONSchema{"type": "string", "format": "duration"}
+	case reflect.PtrTo(t).Implements(textUnmarshalerType):
+		schema = JSONSchema{"type": "string"}
+	default:
+		schema = kindSchema(t.Kind())
+	}
+	if def, ok := field.Tag.Lookup("default"); ok {
+		schema["default"] = typedValue(schema, def)
+	}
+	return schema
+}
+
+func kindSchema(kind reflect.Kind) JSONSchema {
+	switch kind {
+	case reflect.Bool:
+		return JSONSchema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return JSONSchema{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return JSONSchema{"type": "number"}
+	}
+	return JSONSchema{"type": "string"}
+}
+
+// The value of the string as the type of the schema, or the string itself.
+func typedValue(schema JSONSchema, s string) interface{} {
+	switch schema["type"] {
+	case "integer":
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return n
+		}
+	case "number":
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(s); err == nil {
+			return b
+		}
+	}
+	return s
+}
+
+// The schema of the type as JSON.
+func (g *openAPIGen) schema(t reflect.Type) JSONSchema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return JSONSchema{"type": "string", "format": "date-time"}
+	case t.Implements(jsonMarshalerType) || reflect.PtrTo(t).Implements(jsonMarshalerType):
+		return JSONSchema{}
+	case t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType):
+		return JSONSchema{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return JSONSchema{"type": "string", "contentEncoding": "base64"}
+		}
+		return JSONSchema{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return JSONSchema{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Interface:
+		return JSONSchema{}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		name, ok := g.names[t]
+		if !ok {
+			name = g.schemaName(t)
+			g.names[t] = name
+			g.schemas[name] = JSONSchema{} // the recursive ones refer to it
+			g.schemas[name] = g.object(t)
+		}
+		return JSONSchema{"$ref": "#/components/schemas/" + name}
+	}
+	return kindSchema(t.Kind())
+}
+
+// The name of the schema of the struct, prefixed by the package if the name
+// is taken, e.g. "User" or "admin.User".
+func (g *openAPIGen) schemaName(t reflect.Type) string {
+	name := schemaName.ReplaceAllString(t.Name(), "_")
+	if _, taken := g.schemas[name]; !taken {
+		return name
+	}
+	pkg := t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]
+	name = schemaName.ReplaceAllString(pkg, "_") + "." + name
+	for i := 2; ; i++ {
+		if _, taken := g.schemas[name]; !taken {
+			return name
+		}
+		name = strings.TrimSuffix(name, strconv.Itoa(i-1)) + strconv.Itoa(i)
+	}
+}
+
+func (g *openAPIGen) object(t reflect.Type) JSONSchema {
+	object := JSONSchema{"type": "object", "properties": JSONSchema{}}
+	structFields(t, func(field reflect.StructField) {
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			return
+		}
+		if name == "" {
+			name = field.Name
+		}
+		addProperty(object, name, g.schema(field.Type), field.Tag.Get("validate"))
+	})
+	return object
+}
+
+// Add the constraints of the validate tag to the schema, see
+// `request.Validate`. Returns whether the field is required.
+func applyValidate(schema JSONSchema, tag string) (required bool) {
+	bounds := map[string][2]string{
+		"integer": {"minimum", "maximum"},
+		"number":  {"minimum", "maximum"},
+		"string":  {"minLength", "maxLength"},
+		"array":   {"minItems", "maxItems"},
+		"object":  {"minProperties", "maxProperties"},
+	}[fmt.Sprint(schema["type"])]
+
+	for _, item := range strings.Split(tag, ",") {
+		name, params := strings.TrimSpace(item), []string(nil)
+		if i := strings.Index(name, "="); i >= 0 {
+			name, params = name[:i], strings.Fields(name[i+1:])
+		}
+		var bound interface{}
+		if len(params) > 0 {
+			if bounds[0] == "minimum" {
+				if _, err := strconv.ParseFloat(params[0], 64); err == nil {
+					bound = json.Number(params[0])
+				}
+			} else if n, err := strconv.Atoi(params[0]); err == nil {
+				bound = n
+			}
+		}
+
+		switch {
+		case name == "required":
+			required = true
+		case name == "email":
+			schema["format"] = "email"
+		case name == "oneof" && len(params) > 0:
+			enum := make([]interface{}, 0, len(params))
+			for _, p := range params {
+				enum = append(enum, typedValue(schema, p))
+			}
+			schema["enum"] = enum
+		case bound == nil || bounds[0] == "":
+		case name == "min":
+			schema[bounds[0]] = bound
+		case name == "max":
+			schema[bounds[1]] = bound
+		case name == "len":
+			schema[bounds[0]], schema[bounds[1]] = bound, bound
+		}
+	}
+	return required
+}
+
+// Write the document as indented JSON.
+func (doc *OpenAPI) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+// Write the document as YAML, in the same order as the JSON one.
+func (doc *OpenAPI) WriteYAML(w io.Writer) error {
+	buf := new(bytes.Buffer)
+	if err := doc.WriteJSON(buf); err != nil {
+		return err
+	}
+	dec := json.NewDecoder(buf)
+	dec.UseNumber()
+	node, err := decodeYAMLNode(dec)
+	if err != nil {
+		return err
+	}
+	out := new(strings.Builder)
+	node.write(out, 0)
+	_, err = io.WriteString(w, out.String())
+	return err
+}
+
+// A JSON value keeping the order of the keys, to write as YAML.
+type yamlNode struct {
+	// '{', '[' or 0 of a scalar.
+	kind   byte
+	keys   []string
+	values []*yamlNode
+	scalar string
+}
+
+func decodeYAMLNode(dec *json.Decoder) (*yamlNode, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch x := tok.(type) {
+	case json.Delim:
+		node := &yamlNode{kind: byte(x)}
+		for dec.More() {
+			if node.kind == '{' {
+				key, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				node.keys = append(node.keys, key.(string))
+			}
+			value, err := decodeYAMLNode(dec)
+			if err != nil {
+				return nil, err
+			}
+			node.values = append(node.values, value)
+		}
+		_, err := dec.Token() // the closing delim
+		return node, err
+	case string:
+		return &yamlNode{scalar: yamlQuote(x)}, nil
+	case nil:
+		return &yamlNode{scalar: "null"}, nil
+	}
+	return &yamlNode{scalar: fmt.Sprint(tok)}, nil
+}
+
+var yamlPlainKey = regexp.MustCompile(`^[A-Za-z_$][\w$-]*$`)
+
+// Quote the string as JSON, which is a double-quoted YAML scalar.
+func yamlQuote(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+func (n *yamlNode) write(w *strings.Builder, indent int) {
+	pad := strings.Repeat(" ", indent)
+	switch n.kind {
+	case '{':
+		for i, key := range n.keys {
+			if !yamlPlainKey.MatchString(key) {
+				key = yamlQuote(key)
+			}
+			w.WriteString(pad + key + ":")
+			n.values[i].writeValue(w, indent)
+		}
+	case '[':
+		for _, value := range n.values {
+			if value.kind == 0 || len(value.values) == 0 {
+				w.WriteString(pad + "-")
+				value.writeValue(w, indent)
+				continue
+			}
+			// The first line of the item follows "- ".
+			item := new(strings.Builder)
+			value.write(item, indent+2)
+			w.WriteString(pad + "- " + strings.TrimPrefix(item.String(), pad+"  "))
+		}
+	}
+}
+
+// Write the value following a key or "-", inline if it's a scalar or empty.
+func (n *yamlNode) writeValue(w *strings.Builder, indent int) {
+	switch {
+	case n.kind == 0:
+		w.WriteString(" " + n.scalar + "\n")
+	case len(n.values) == 0 && n.kind == '{':
+		w.WriteString(" {}\n")
+	case len(n.values) == 0:
+		w.WriteString(" []\n")
+	default:
+		w.WriteString("\n")
+		n.write(w, indent+2)
+	}
+}
+
+// Serve the OpenAPI document of the routes at the pattern, as YAML if it ends
+// with ".yaml" or ".yml", or as JSON. The document is generated on each
+// request, so it's up to date with the routes. See `NewOpenAPI`.
+// e.g. mux.ServeOpenAPI("/openapi.json", OpenAPIInfo{Title: "Blog", Version: "1.0"})
+func (m *Mux) ServeOpenAPI(pattern string, info OpenAPIInfo, opts ...RouteOption) {
+	isYAML := strings.HasSuffix(pattern, ".yaml") || strings.HasSuffix(pattern, ".yml")
+	opts = append([]RouteOption{WithMetadata(openAPIHidden, true)}, opts...)
+	m.Get(pattern, func(rw http.ResponseWriter, r *http.Request) {
+		doc := NewOpenAPI(info, m.Routes())
+		buf := new(bytes.Buffer)
+		var err error
+		if isYAML {
+			rw.Header().Set("Content-Type", "application/yaml")
+			err = doc.WriteYAML(buf)
+		} else {
+			rw.Header().Set("Content-Type", "application/json")
+			err = doc.WriteJSON(buf)
+		}
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		buf.WriteTo(rw)
+	}, opts...)
+}
@@ -0,0 +1,104 @@
+package mux
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/ggicci/jungo/program"
+)
+
+// A route option configures the route being registered to `Mux`.
+// e.g. mux.Handle("/admin/", h, WithMiddleware(auth))
+type RouteOption func(*routeOptions)
+
+type routeOptions struct {
+	name        string
+	middlewares []Middleware
+	defaults    RouteVariables
+	host        string
+	matchers    []Matcher
+	versions    *VersionRange
+	metadata    Metadata
+}
+
+func newRouteOptions(opts []RouteOption) *routeOptions {
+	ro := &routeOptions{}
+	for _, opt := range opts {
+		opt(ro)
+	}
+	return ro
+}
+
+// Wrap the handler of the route with the middlewares, inside the global ones
+// added by `Mux.Use`. The first one is the outermost.
+func WithMiddleware(mw ...func(http.Handler) http.Handler) RouteOption {
+	return func(ro *routeOptions) {
+		for _, x := range mw {
+			ro.middlewares = append(ro.middlewares, x)
+		}
+	}
+}
+
+// Name the route, so its URL can be built by `Mux.URL`.
+func WithName(name string) RouteOption {
+	return func(ro *routeOptions) { ro.name = name }
+}
+
+// Set the default values of the route variables, which show up in the route
+// variables when absent from the path, e.g. the ones of optional routes.
+// e.g. mux.Get("/list/{page?}\\d+", h, WithDefaults("page", "1"))
+func WithDefaults(pairs ...string) RouteOption {
+	return func(ro *routeOptions) {
+		if ro.defaults == nil {
+			ro.defaults = make(RouteVariables)
+		}
+		for i := 0; i+1 < len(pairs); i += 2 {
+			ro.defaults[pairs[i]] = pairs[i+1]
+		}
+	}
+}
+
+// Only match the route on the requests to the host, e.g. "{tenant}.example.com".
+// The route variables in the host are merged into the ones in the path. The
+// host-agnostic routes are the fallback of the ones with a host.
+func WithHost(host string) RouteOption {
+	return func(ro *routeOptions) { ro.host = host }
+}
+
+// Only match the route on the requests which all the matchers match. Handlers
+// can share a pattern and method with different matchers, the ones with matchers
+// are tried in the order registered, before the one without matchers.
+// e.g. mux.Get("/report", csvReport, WithMatchers(MatchQuery("format", "csv")))
+func WithMatchers(ms ...Matcher) RouteOption {
+	return func(ro *routeOptions) { ro.matchers = append(ro.matchers, ms...) }
+}
+
+// Serve the route on the API versions from min to max, both inclusive. If max
+// is "", there's no upper bound. See `Versioning`.
+// e.g. mux.Get("/users", listUsersV2, WithVersion("2.0", ""))
+func WithVersion(min, max string) RouteOption {
+	return func(ro *routeOptions) {
+		ro.versions = &VersionRange{Min: program.ParseVersionNumber(strings.TrimLeft(min, "vV"))}
+		if max != "" {
+			v := program.ParseVersionNumber(strings.TrimLeft(max, "vV"))
+			ro.versions.Max = &v
+		}
+	}
+}
+
+// Arbitrary data of a route, e.g. the auth scopes, the rate-limit class and the
+// owner team, see `WithMetadata`.
+type Metadata map[string]interface{}
+
+// Attach the metadata to the handler of the route. Middlewares read it by
+// `RouteMetadata`, tools by `Router.Walk`. Given multiple times, the values of
+// the same key are overwritten.
+// e.g. mux.Get("/admin/users", h, WithMetadata("scopes", []string{"admin"}))
+func WithMetadata(key string, value interface{}) RouteOption {
+	return func(ro *routeOptions) {
+		if ro.metadata == nil {
+			ro.metadata = make(Metadata)
+		}
+		ro.metadata[key] = value
+	}
+}
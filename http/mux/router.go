@@ -3,31 +3,44 @@ package mux
 import (
 	"bytes"
 	"fmt"
+	"net/http"
 	"reflect"
 	"regexp"
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 )
 
 type RouteVariables map[string]string
 
+// The routes are kept in an immutable snapshot, see `routeTable`. Matching
+// never blocks, and the changes are published at once, see `Update`.
+// The zero value is ready to use.
 type Router struct {
-	mutex sync.RWMutex
-	root  *route
+	// Serializes the changes, matching doesn't take it.
+	mutex sync.Mutex
+	table atomic.Value // *routeTable
+
+	options pathOptions
 }
 
 const (
-	kAnyPattern int = 1 << iota
+	kWildcardPattern int = 1 << iota
+	kAnyPattern
 	kRegexPattern
+	kPartialPattern
 	kAbsolutePattern
 )
 
+// The name suffix of a wildcard route, which captures the rest of the path,
+// e.g. pattern "/files/{path...}" matches "/files/a/b/c.txt" with "a/b/c.txt".
+const wildcardSuffix = "..."
+
+// A route is a part of the pattern between "/", parsed by `parseRoutePart`.
 type route struct {
-	parent   *route
-	child    *route
-	lsibling *route
-	rsibilng *route
+	// The part as written in the pattern, e.g. "{line}\d{1,}" and "{page?}".
+	part string
 
 	// "/{category}/{file}/{line}\d{1,}"
 	// names are "category", "file" and "line"
@@ -37,124 +50,298 @@ type route struct {
 	// should use `regex` to match first, else fallback to use `pattern`
 	regex *regexp.Regexp
 
-	index    int
 	priority int
-	// min/max depth
-	depths [2]int
+	// "{name?}", see `trimOptionalRoute`.
+	optional bool
+	// The route variables with converters in order, only of the partial
+	// routes, e.g. "{id:int}", see `Converter`.
+	converters []routeConverter
+}
 
-	// handler, or may be uesed to store any data you want
-	handler interface{}
+func (r *route) String() string { return r.part }
+
+// Report whether the route matches the part of a path.
+func (r *route) match(part string) bool {
+	switch r.priority {
+	case kWildcardPattern:
+		// The rest of the path is captured by `leaf.vars`.
+		return true
+	case kPartialPattern, kRegexPattern:
+		if len(r.converters) > 0 {
+			return r.convert(part, nil)
+		}
+		return r.regex.MatchString(part)
+	case kAnyPattern:
+		// "Any pattern" only matches non-empty part.
+		return part != ""
+	case kAbsolutePattern:
+		// http://stackoverflow.com/questions/7996919/should-url-be-case-sensitive
+		// URLs in general are case-sensitive (with the exception of machine names).
+		// There may be URLs, or parts of URLs, where case doesn't matter,
+		// but identifying these may not be easy.
+		// Users should always consider that URLs are case-sensitive.
+		return r.pattern == part
+	}
+	return false
 }
 
-func (r *route) String() string {
-	h := "0"
-	if r.handler != nil {
-		h = fmt.Sprintf("%p", r.handler)
+// Fill in the route variables from the part matched by the route.
+func (r *route) fill(part string, rvs RouteVariables) {
+	if r.priority == kPartialPattern {
+		submatches := r.regex.FindStringSubmatch(part)
+		if submatches == nil {
+			return
+		}
+		for i, name := range r.regex.SubexpNames() {
+			if name != "" {
+				rvs[name] = submatches[i]
+			}
+		}
+		return
+	}
+	if r.name != "" {
+		rvs[r.name] = part
 	}
-	str := fmt.Sprintf("/%s{n:%s;[%d,%d,%d,%d];h:%s;}",
-		r.pattern, r.name, r.index, r.priority, r.depths[0], r.depths[1], h)
-	return str
 }
 
-func (r *route) isTail() bool      { return r.child == nil }
-func (r *route) isAnyTail() bool   { return r.isTail() && r.priority == kAnyPattern }
-func (r *route) isSlashTail() bool { return r.isTail() && r.name+r.pattern == "" }
+// Report whether the routes are the same regardless of the names, i.e. match
+// the same parts.
+func (r *route) equal(other *route) bool {
+	return r.priority == other.priority && r.pattern == other.pattern &&
+		sameConverters(r.converters, other.converters)
+}
 
-func (r *route) match(part string, justRouteLevel bool, rvs RouteVariables) bool {
-	result := false
+// An endpoint is bound to the tail route of a pattern. It holds the handlers
+// registered on the pattern per HTTP method. The handlers bound to method ""
+// serve the methods that have no handler of their own. Handlers of the same
+// method are told apart by their matchers, see `WithMatchers`.
+// Endpoints are immutable once added to the tree, changed by copy.
+type endpoint struct {
+	pattern string
+	// The routes of the pattern, see `makeRoutes`.
+	routes   []*route
+	handlers map[string][]*binding
+}
 
-	if justRouteLevel {
-		for {
-			_, tomatch, isRegex := splitRouteNameAndMatchPattern(part)
-			if isRegex && r.regex == nil {
-				result = false
-				break
-			}
-			result = (r.pattern == tomatch)
-			break
-		}
-	} else {
-		switch r.priority {
-		case kAnyPattern:
-			// "Any pattern" only matches non-empty part.
-			result = part != ""
-		case kRegexPattern:
-			result = r.regex != nil && r.regex.MatchString(part)
-		case kAbsolutePattern:
-			// http://stackoverflow.com/questions/7996919/should-url-be-case-sensitive
-			// URLs in general are case-sensitive (with the exception of machine names).
-			// There may be URLs, or parts of URLs, where case doesn't matter,
-			// but identifying these may not be easy.
-			// Users should always consider that URLs are case-sensitive.
-			result = r.pattern == part
-		}
+// A handler bound to an endpoint, with the options given at registration.
+type binding struct {
+	handler interface{}
+	options *routeOptions
+}
 
-		// Fill in the route variables map.
-		if result && r.name != "" {
-			rvs[r.name] = part
+// Report whether the matchers of the binding all match the request.
+// Without the request, only the binding without matchers matches.
+func (b *binding) match(r *http.Request) bool {
+	if r == nil {
+		return len(b.options.matchers) == 0
+	}
+	for _, m := range b.options.matchers {
+		if !m.Match(r) {
+			return false
 		}
 	}
-
-	return result
+	return true
 }
 
-func (r *route) equal(other *route) bool {
-	if r.index != other.index {
-		return false
+// Report whether the binding is preferred to the other one, both matching
+// the request. The ones with matchers first, in the order registered, then the
+// one of the highest API version, then the one without version.
+func (b *binding) prefer(other *binding) bool {
+	bm, om := len(b.options.matchers) > 0, len(other.options.matchers) > 0
+	if bm != om {
+		return bm
 	}
-	if r.pattern != other.pattern {
+	if bm {
 		return false
 	}
-	if r.name != other.name {
-		return false
+	bv, ov := b.options.versions, other.options.versions
+	if bv == nil || ov == nil {
+		return bv != nil
 	}
-	if r.priority != other.priority {
-		return false
+	return bv.Min.Compare(ov.Min) > 0
+}
+
+// Get the binding for the method which matches the request and the API version
+// it asks for, see `binding.prefer`, or the one for any method if none of the
+// method matches. `bound` reports whether there're bindings for the method, or
+// for any method, even if none matches.
+func (ep *endpoint) binding(method string, r *http.Request) (b *binding, bound bool) {
+	version := requestedAPIVersion(r)
+	for _, m := range []string{method, ""} {
+		if len(ep.handlers[m]) == 0 {
+			continue
+		}
+		bound = true
+		for _, x := range ep.handlers[m] {
+			if !x.match(r) || !x.options.versions.Contains(version) {
+				continue
+			}
+			if b == nil || x.prefer(b) {
+				b = x
+			}
+		}
+		if b != nil {
+			return b, true
+		}
 	}
-	return true
+	return nil, bound
 }
 
-func NewRouter() *Router { return &Router{root: &route{index: -1, pattern: ""}} }
+// API versions of the bindings for the method and the ones for any method,
+// which match the request except the version. Returns nil if any of them is
+// without version.
+func (ep *endpoint) versions(method string, r *http.Request) []VersionRange {
+	var versions []VersionRange
+	for _, m := range []string{method, ""} {
+		for _, x := range ep.handlers[m] {
+			if !x.match(r) {
+				continue
+			}
+			if x.options.versions == nil {
+				return nil
+			}
+			versions = append(versions, *x.options.versions)
+		}
+	}
+	sort.Sort(byVersionRange(versions))
+	return versions
+}
 
-// Just be responsible for mapping patterns to their specific handlers.
-// Build a tree internally.
-func (rt *Router) Handle(pattern string, handler interface{}) error {
-	if isNil(handler) {
-		return fmt.Errorf("nil handler")
+// Get a copy of the endpoint with the binding added. Bindings without matchers
+// conflict if their API versions overlap, see `WithVersion`.
+func (ep *endpoint) bind(method string, b *binding) (*endpoint, error) {
+	if len(b.options.matchers) == 0 {
+		for _, x := range ep.handlers[method] {
+			if len(x.options.matchers) == 0 && x.options.versions.overlaps(b.options.versions) {
+				return nil, fmt.Errorf("pattern %q already exists", methodPattern(method, ep.pattern))
+			}
+		}
+	}
+	handlers := make(map[string][]*binding, len(ep.handlers)+1)
+	for m, bindings := range ep.handlers {
+		handlers[m] = bindings
 	}
+	bindings := ep.handlers[method]
+	handlers[method] = append(bindings[:len(bindings):len(bindings)], b)
+	return &endpoint{pattern: ep.pattern, routes: ep.routes, handlers: handlers}, nil
+}
 
-	// Make routes from the pattern.
-	routes, err := makeRoutes(pattern, handler)
-	if err != nil {
-		return err
+// Sorted methods which have a handler bound.
+func (ep *endpoint) methods() []string {
+	methods := make([]string, 0, len(ep.handlers))
+	for method := range ep.handlers {
+		methods = append(methods, method)
 	}
+	sort.Strings(methods)
+	return methods
+}
 
-	// Find if there already exists a same pattern.
-	if rt.PatternExists(pattern) {
-		return fmt.Errorf("pattern %q already exists", pattern)
+func (ep *endpoint) String() string {
+	methods := ep.methods()
+	if len(methods) > 0 && methods[0] == "" {
+		methods[0] = "*"
 	}
+	return strings.Join(methods, ",")
+}
 
-	rt.mutex.Lock()
-	defer rt.mutex.Unlock()
+func NewRouter(opts ...RouterOption) *Router {
+	rt := &Router{}
+	for _, opt := range opts {
+		opt(rt)
+	}
+	return rt
+}
 
-	// Rebuild the tree.
-	rt.rebuildRouteTree(routes)
-	return nil
+// Just be responsible for mapping patterns to their specific handlers.
+// Build a radix tree internally, see `node`.
+// The handler serves any HTTP method which isn't bound by `HandleMethod`.
+//
+// A part of the pattern between "/" is one of:
+//   - "users", the static text.
+//   - "{id}", a variable of any text.
+//   - "{id}\d+", a variable of the regex matching the whole part.
+//   - "{id:\d+}.json" or "{w}x{h}.png", the variables inside the part together
+//     with text, the regex of a variable given after ":", see `isPartialRoute`.
+//     NB: "{id}\d+.json" is a regex route as a whole, the variable captures
+//     "13.json" of "/13.json", use "{id:\d+}.json" to capture "13" only.
+//   - "{id:int}", a variable of the converter, see `RegisterConverter`.
+//   - "{year?}\d{4}", an optional variable, see `expandOptionalRoutes`.
+//   - "{path...}", the last variable capturing the rest of the path.
+func (rt *Router) Handle(pattern string, handler interface{}, opts ...RouteOption) error {
+	return rt.HandleMethod("", pattern, handler, opts...)
+}
+
+// Bind the handler to the pattern for the specified HTTP method only.
+// The same pattern can be bound with different methods, e.g. "GET /a" and
+// "POST /a" do not conflict.
+func (rt *Router) HandleMethod(method, pattern string, handler interface{}, opts ...RouteOption) error {
+	return rt.Update(func(tx *RouterTx) error {
+		return tx.HandleMethod(method, pattern, handler, opts...)
+	})
+}
+
+// Remove the pattern together with all of the handlers bound to it. The other
+// patterns on the way are left intact, e.g. "/a" of "/a/b".
+func (rt *Router) Remove(pattern string) error {
+	return rt.Update(func(tx *RouterTx) error {
+		return tx.Remove(pattern)
+	})
+}
+
+// Remove the handlers bound to the method on the pattern. The pattern is
+// removed if no handler is left.
+func (rt *Router) RemoveMethod(method, pattern string) error {
+	return rt.Update(func(tx *RouterTx) error {
+		return tx.RemoveMethod(method, pattern)
+	})
+}
+
+// Replace all of the handlers bound to the pattern with the handler, which
+// serves any HTTP method. Returns an error if the pattern isn't registered.
+func (rt *Router) Replace(pattern string, handler interface{}, opts ...RouteOption) error {
+	return rt.Update(func(tx *RouterTx) error {
+		return tx.Replace(pattern, handler, opts...)
+	})
+}
+
+// Replace the handlers bound to the method on the pattern with the handler.
+// Returns an error if the pattern isn't registered.
+func (rt *Router) ReplaceMethod(method, pattern string, handler interface{}, opts ...RouteOption) error {
+	return rt.Update(func(tx *RouterTx) error {
+		return tx.ReplaceMethod(method, pattern, handler, opts...)
+	})
+}
+
+func methodPattern(method, pattern string) string {
+	if method == "" {
+		return pattern
+	}
+	return method + " " + pattern
 }
 
 type matchResult struct {
 	Path string
-	// The handler which exactly matches the full path. Maybe nil.
+	// The pattern which matches the full path. Empty if no pattern matched.
+	Pattern string
+	// The handler which exactly matches the full path and the method. Maybe nil.
 	Handler interface{}
-	// Non-nil handlers on the way in a reverse order. For example:
+	// Methods bound to the pattern which matches the full path, only set
+	// when none of them serves the requested method, i.e. "405 Method Not Allowed".
+	AllowedMethods []string
+	// API versions of the handlers bound to the pattern and the method, only
+	// set when none of them serves the version asked for, see `WithVersion`.
+	SupportedVersions []VersionRange
+	// Non-nil handlers on the way, the shallow ones first. They're only
+	// collected if nothing is found for the full path, to fallback to.
+	// For example:
 	// rt.Handle("/a/", handler_1)
 	// rt.Handle("/a/b/", handler_2)
 	// rt.Handle("/a/b/c", handler_3)
-	// rt.Match("/a/b/c") will get result like this:
+	// rt.Match("GET", "/a/b/d") will get result like this:
 	// {
-	//    Path: "/a/b/c",
-	//    Handler: handler_3,
-	//    HandlersOnTheWay: []{handler_2, handler_1},
+	//    Path: "/a/b/d",
+	//    Handler: nil,
+	//    HandlersOnTheWay: []{handler_1, handler_2},
 	//    ...
 	// }
 	HandlersOnTheWay []RouteMatchItem
@@ -166,351 +353,195 @@ type matchResult struct {
 	//   "file":    "main.go",
 	//   "line":    "13",
 	// }
+	// Nil if the pattern has no route variables. If nothing is found, they're
+	// the ones of the deepest handler on the way.
 	RouteVars RouteVariables
+	// The typed values of the route variables with converters, e.g. an int of
+	// "{id:int}". Nil if none, see `Converter`.
+	RouteValues RouteValues
+	// The path spelled as the static parts of the pattern, only set if it's
+	// not the path, see `IgnoreCase` and `NormalizeUnicode`.
+	CanonicalPath string
+	// The metadata of the handler, see `WithMetadata`.
+	Metadata Metadata
 }
 
-// Match the real path to a specified handler.
-// You should give a normalized path. (eg. path.Clean(...))
-func (rt *Router) Match(path string) matchResult {
-	return rt.match(path, false)
-}
-
-func (rt *Router) PatternExists(pattern string) bool {
-	mr := rt.match(pattern, true)
-	return mr.Handler != nil
+// The route matched, to store in the request context.
+func (mr *matchResult) route() *routeContext {
+	return &routeContext{pattern: mr.Pattern, vars: mr.RouteVars, values: mr.RouteValues, metadata: mr.Metadata}
 }
 
-type RouteMatchItem struct {
-	Path    string
-	Handler interface{}
+// Match the real path and the HTTP method to a specified handler.
+// You should give a normalized path. (eg. path.Clean(...))
+// NB: The handlers registered with matchers never match, see `MatchRequest`.
+func (rt *Router) Match(method, path string) matchResult {
+	return rt.MatchRequest(method, path, nil)
 }
 
-type traceTable map[int][]RouteMatchItem
-
-func (t traceTable) tryInsert(depth, pos int, path string, handler interface{}) {
-	if handler == nil {
-		return
-	}
-	if _, exists := t[depth]; !exists {
-		t[depth] = make([]RouteMatchItem, 2)
+// Match the real path and the HTTP method to a specified handler, then choose
+// among the handlers of the pattern by their matchers on the request.
+func (rt *Router) MatchRequest(method, path string, r *http.Request) (mr matchResult) {
+	root := rt.load().root
+	method = strings.ToUpper(method)
+	mr.Path = path
+	path = rt.options.normalize(path)
+	key := rt.options.key(path)
+	if l := match(root, path, key, nil); l != nil {
+		mr.resolveMethod(l, path, method, r)
+		if rt.options != (pathOptions{}) {
+			if canonical := l.canonical(path); canonical != mr.Path {
+				mr.CanonicalPath = canonical
+			}
+		}
 	}
-	if t[depth][pos].Handler == nil {
-		t[depth][pos].Path = path
-		t[depth][pos].Handler = handler
+	if mr.Handler == nil && len(mr.AllowedMethods) == 0 && len(mr.SupportedVersions) == 0 {
+		mr.resolveHandlersOnTheWay(trace(root, path, key), method, r)
 	}
+	return
 }
 
-func (t traceTable) getHandlersOnTheWay() []RouteMatchItem {
-	depths, handlers := make([]int, 0), make([]RouteMatchItem, 0)
-	for depth := range t {
-		depths = append(depths, depth)
+// Report whether the pattern has been registered. If methods are given,
+// only report whether any of them has been bound to the pattern.
+func (rt *Router) PatternExists(pattern string, methods ...string) bool {
+	pattern = rt.options.normalize(strings.TrimSpace(pattern))
+	ep := rt.lookupEndpoint(pattern)
+	if ep == nil || ep.pattern != pattern {
+		return false
 	}
-	if len(depths) == 0 {
-		return handlers
+	if len(methods) == 0 {
+		return true
 	}
-	sort.Ints(depths)
-	for _, depth := range depths {
-		for pos := 0; pos < 2; pos++ {
-			if t[depth][pos].Handler != nil {
-				handlers = append(handlers, RouteMatchItem{
-					Path:    t[depth][pos].Path,
-					Handler: t[depth][pos].Handler,
-				})
-			}
+	for _, method := range methods {
+		if len(ep.handlers[strings.ToUpper(method)]) > 0 {
+			return true
 		}
 	}
-	// fmt.Printf("sorted handlers: %v\n", handlers)
-	return handlers
+	return false
 }
 
-func (rt *Router) match(path string, justRouteLevel bool) (result matchResult) {
-	rt.mutex.RLock()
-	defer rt.mutex.RUnlock()
+// Find the endpoint of the pattern, or the one of a pattern which is the same
+// regardless of the names of the route variables.
+func (rt *Router) lookupEndpoint(pattern string) *endpoint {
+	routes, err := makeRoutes(pattern)
+	if err != nil {
+		return nil
+	}
 
-	result.Path = path
+	if l := rt.load().root.find(rt.options.tokenize(routes)); l != nil {
+		return l.endpoint
+	}
+	return nil
+}
 
+// Find the leaf which matches the full path, see `node.lookup`. The key is
+// compared with the static text, see `pathOptions.key`.
+func match(root *node, path, key string, t *traceTable) *leaf {
 	// The root.
 	if path == "" || path == "/" {
-		result.Handler = rt.root.handler
-		return
+		if t == nil {
+			return root.find(tokenize(nil))
+		}
+		return nil
 	}
 
 	// Filter out some dirty paths.
 	// Path with multiple trailing "/" is a kind of them.
 	if strings.HasSuffix(path, "//") {
-		return
+		return nil
 	}
 
-	var (
-		matched, backtrack bool
-		theway             string
-		tbltrace           traceTable     = make(traceTable)
-		p                  *route         = rt.root
-		parts              []string       = strings.Split(path[1:], "/")
-		rvs                RouteVariables = make(RouteVariables)
-	)
-
-	result.RouteVars = rvs
-
-	for i := 0; i < len(parts); i++ {
-		if i < 0 {
-			p = nil
-			goto LAB_MATCH_END
-		}
-
-		theway = "/" + strings.Join(parts[:i+1], "/")
-
-		// Here p shouldn't be nil.
-		if backtrack {
-			p = p.rsibilng
-		} else {
-			p = p.child
-		}
-
-		matched, backtrack = false, false
-
-		if p == nil {
-			goto LAB_MATCH_END
-		}
-
-		// Compares with the routes on the same level, excluding the most right oue.
-		for ; p != nil && p.rsibilng != nil; p = p.rsibilng {
-
-			// Em... Originally, I want to use this judgement statement to accelerate the matching
-			// procedure. But now I want to traverse the whole tree to find the apporopriate "fallback"
-			// handlers for each sub path (i.e. "/a", "/a/" of path "/a/b"), this statement should be commented.
-			// if !justRouteLevel && (len(parts)-p.depths[0]-i < -1 || len(parts)-p.depths[1]-i > 1) {
-			//  continue
-			// }
-
-			if p.match(parts[i], justRouteLevel, rvs) {
-				matched = true
-				break
-			}
-		}
-
-		// Compares with the most right node.
-		if !matched {
-			matched = p.match(parts[i], justRouteLevel, rvs)
-		}
-
-		if !matched {
-			// Backtrack.
-			backtrack = true
-			for i--; p != nil && p.rsibilng == nil; p = p.parent {
-				if p == rt.root {
-					break
-				}
-				i--
-			}
-		} else {
-			if strings.HasSuffix(theway, "/") {
-				tbltrace.tryInsert(i-1, 1, theway, p.handler)
-			} else {
-				tbltrace.tryInsert(i, 0, theway, p.handler)
-				if p.child != nil && (p.child.isSlashTail() || p.child.isAnyTail()) {
-					tbltrace.tryInsert(i, 1, theway+"/", p.child.handler)
-				}
-			}
-
-		}
-	}
-
-LAB_MATCH_END:
-	if p != nil {
-		result.Handler = p.handler
-	}
-	result.HandlersOnTheWay = tbltrace.getHandlersOnTheWay()
-	return
+	return root.lookup(path, key, 0, t, nil)
 }
 
-func (rt *Router) rebuildRouteTree(routes []*route) {
-	parent := rt.root
-	for _, node := range routes {
-		rt.addChildRouteNode(parent, node)
-		if node.index == -1 {
-			rt.rearrange(rt.root)
-		} else {
-			rt.rearrange(parent)
-		}
-		parent = node
-	}
+// Collect the handlers matched on the way of the path, see `traceTable`.
+func trace(root *node, path, key string) traceTable {
+	t := make(traceTable, 0)
+	match(root, path, key, &t)
+	sort.Sort(t)
+	return t
 }
 
-// Rearrange the routes. Sort by priority and other policies, like pattern length
-// and pattern alphabetic order.
-// ## Why I rearrange the routes?
-// For instance, pattern "/cpp/19911110/{file}\\w+?\.cxx" and "/cpp/{date}\\d{8}/born.cxx"
-// both can match path "/cpp/19911110/born.txt", but the former has a higher priority,
-// so the handler bound to which will be returned.
-func (rt *Router) rearrange(parent *route) {
-	if parent == nil {
-		return
-	}
-	children := make([]*route, 0)
-	for p := parent.child; p != nil; p = p.rsibilng {
-		children = append(children, p)
-	}
-
-	if len(children) == 0 {
-		return
-	}
-
-	sort.Sort(byRoutePriority(children))
-
-	// Link them.
-	for i := 0; i < len(children)-1; i++ {
-		children[i].rsibilng = children[i+1]
-	}
-	for i := len(children) - 1; i > 0; i-- {
-		children[i].lsibling = children[i-1]
+// Resolve the leaf found by `match` to the handler bound to the method.
+// If the method has handlers bound but none of them matches the request, the
+// result is "not found" instead of "method not allowed".
+func (mr *matchResult) resolveMethod(l *leaf, path, method string, r *http.Request) {
+	ep := l.endpoint
+	mr.Pattern = ep.pattern
+	if b, bound := ep.binding(method, r); b != nil {
+		mr.Handler = b.handler
+		mr.Metadata = b.options.metadata
+		mr.RouteVars = l.vars(path)
+		mr.RouteValues = l.values(path)
+		mr.fillDefaults(b.options.defaults)
+	} else if bound {
+		mr.SupportedVersions = ep.versions(method, r)
+	} else {
+		for _, m := range ep.methods() {
+			if b, _ := ep.binding(m, r); b != nil {
+				mr.AllowedMethods = append(mr.AllowedMethods, m)
+			}
+		}
 	}
-	// Head and tail.
-	children[0].lsibling = nil
-	children[len(children)-1].rsibilng = nil
-	parent.child = children[0]
 }
 
-type byRoutePriority []*route
-
-func (b byRoutePriority) Len() int      { return len(b) }
-func (b byRoutePriority) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
-func (b byRoutePriority) Less(i, j int) bool {
-	if b[i].priority > b[j].priority {
-		return true
-	}
-	if b[i].priority == b[j].priority {
-		if len(b[i].pattern) < len(b[j].pattern) {
-			return true
+// Resolve the leaves on the way to the handlers bound to the method.
+func (mr *matchResult) resolveHandlersOnTheWay(t traceTable, method string, r *http.Request) {
+	for _, item := range t {
+		b, _ := item.leaf.endpoint.binding(method, r)
+		if b == nil {
+			continue
 		}
-		if len(b[i].pattern) == len(b[j].pattern) {
-			return b[i].pattern < b[j].pattern
+		mi := RouteMatchItem{
+			Path:        item.path,
+			Pattern:     item.leaf.endpoint.pattern,
+			Handler:     b.handler,
+			Metadata:    b.options.metadata,
+			RouteVars:   item.leaf.vars(item.path),
+			RouteValues: item.leaf.values(item.path),
 		}
+		mr.HandlersOnTheWay = append(mr.HandlersOnTheWay, mi)
+		mr.RouteVars, mr.RouteValues = mi.RouteVars, mi.RouteValues
 	}
-	return false
 }
 
-func (rt *Router) addChildRouteNode(parent, node *route) {
-	// The root.
-	if node.index == -1 {
-		if rt.root.child != nil {
-			rt.root.child.parent = node
-		}
-		node.child = rt.root.child
-		rt.root = node
+// Fill in the default values of the route variables absent from the path.
+func (mr *matchResult) fillDefaults(defaults RouteVariables) {
+	if len(defaults) == 0 {
 		return
 	}
-
-	node.parent = parent
-	sib := parent.child
-
-	// `node` is the first child of `parent`.
-	if sib == nil {
-		parent.child = node
-		return
+	if mr.RouteVars == nil {
+		mr.RouteVars = make(RouteVariables, len(defaults))
 	}
-
-	// Iterate on the same level to find if there's an equivalent node.
-	for ; sib != nil && sib.rsibilng != nil; sib = sib.rsibilng {
-		if sib.equal(node) {
-			break
+	for name, value := range defaults {
+		if _, ok := mr.RouteVars[name]; !ok {
+			mr.RouteVars[name] = value
 		}
 	}
-
-	// Take the place of the old one.
-	if sib.equal(node) {
-		node.child = sib.child
-
-		// Fix issue: missing handler.
-		// eg.
-		// mux.Handle("/admin", handler_1)
-		// mux.Handle("/admin/tag", handler_2)
-		//
-		//  /admin --> got a nil handler (be overwritten)
-		if sib.handler != nil {
-			if node.handler == nil {
-				node.handler = sib.handler
-			} else {
-				panic("something went wrong")
-			}
-		}
-
-		node.rsibilng = sib.rsibilng
-		node.lsibling = sib.lsibling
-
-		if node.depths[0] > sib.depths[0] {
-			node.depths[0] = sib.depths[0]
-		}
-		if node.depths[1] < sib.depths[1] {
-			node.depths[1] = sib.depths[1]
-		}
-
-		if sib.lsibling != nil {
-			sib.lsibling.rsibilng = node
-		}
-
-		if sib.rsibilng != nil {
-			sib.rsibilng.lsibling = node
-		}
-
-		if sib.child != nil {
-			sib.child.parent = node
-		}
-
-		if sib == parent.child {
-			parent.child = node
-		}
-		return
-	}
-
-	// Append.
-	sib.rsibilng = node
-	node.lsibling = sib
 }
 
-func dumpRoute(buf *bytes.Buffer, r *route) {
-	for p := r; p != nil; p = p.rsibilng {
-		stack := make([]string, 0)
-		for q := p.parent; q != nil; q = q.parent {
-
-			if p.parent == q {
-				if p.rsibilng == nil {
-					stack = append(stack, "└── ")
-				} else {
-					stack = append(stack, "├── ")
-				}
-				continue
-			}
-
-			var z *route
-			for z = p.parent; z != nil && z.parent != q; z = z.parent {
-				// Ancestor bubble up.
-			}
-			if z != nil && z.rsibilng != nil {
-				stack = append(stack, "│   ")
-				continue
-			}
-			stack = append(stack, "    ")
-		}
-
-		// Pop stack.
-		for i := len(stack) - 1; i >= 0; i-- {
-			fmt.Fprintf(buf, "%s", stack[i])
-		}
-		fmt.Fprintf(buf, "%v\n", p)
-
-		dumpRoute(buf, p.child)
-	}
-
+type RouteMatchItem struct {
+	Path     string
+	Pattern  string
+	Handler  interface{}
+	Metadata Metadata
+	// The route variables of the pattern, and the typed values of them, i.e.
+	// of this handler, not the ones of `matchResult` if it's not the deepest.
+	RouteVars   RouteVariables
+	RouteValues RouteValues
 }
 
 func (rt *Router) DumpTree() string {
 	buf := bytes.NewBuffer(make([]byte, 0, 2048))
-	dumpRoute(buf, rt.root)
+	// The root is "", all of the patterns start with "/".
+	for _, c := range rt.load().root.static {
+		c.dump(buf, "", "")
+	}
 	return buf.String()
 }
 
-func makeRoutes(pattern string, handler interface{}) ([]*route, error) {
+// Parse the pattern to the routes of its parts. The optional ones are trimmed
+// and marked, see `expandOptionalRoutes`.
+func makeRoutes(pattern string) ([]*route, error) {
 	pattern = strings.TrimSpace(pattern)
 
 	if pattern == "" {
@@ -523,66 +554,142 @@ func makeRoutes(pattern string, handler interface{}) ([]*route, error) {
 
 	// The root.
 	if pattern == "/" {
-		return []*route{&route{index: -1, pattern: "", handler: handler}}, nil
+		return nil, nil
 	}
 
 	parts := strings.Split(pattern[1:], "/")
 	nameDuplicated := make(map[string]bool)
-	routes := make([]*route, 0)
+	routes := make([]*route, 0, len(parts))
 	depth := len(parts)
 
 	for i, part := range parts {
-		r := &route{index: i, depths: [2]int{depth - i, depth - i}, handler: nil}
-
 		// Any part shouldn't be empty excluding the last one.
 		if part == "" && i != (depth-1) {
 			return nil, fmt.Errorf("empty route (or duplicated '/') in pattern %q", pattern)
 		}
 
-		var (
-			isRegex      bool
-			originalPart = part
-		)
+		part, optional := trimOptionalRoute(part)
+		if i > 0 && !optional && routes[i-1].optional {
+			return nil, fmt.Errorf("route %q follows an optional one in pattern %q", part, pattern)
+		}
 
-		r.name, part, isRegex = splitRouteNameAndMatchPattern(part)
-		// Check duplicated name in the same pattern.
-		if r.name != "" && nameDuplicated[r.name] {
-			return nil, fmt.Errorf("duplicated route name %q in pattern %q",
-				r.name, pattern)
+		r, err := parseRoutePart(part)
+		if err != nil {
+			return nil, fmt.Errorf("%s in pattern %q", err.Error(), pattern)
 		}
-		nameDuplicated[r.name] = true
+		r.part, r.optional = parts[i], optional
 
-		if isRegex {
-			if part == "^$" {
-				return nil, fmt.Errorf("empty route (regex) %q in pattern %q", part, pattern)
-			}
-			if regex, err := regexp.Compile(part); err != nil {
-				return nil, fmt.Errorf("unable to compile route %q in pattern %q, compile error: %s",
-					originalPart, pattern, err.Error())
-			} else {
-				r.regex = regex
+		if r.priority == kWildcardPattern && i != depth-1 {
+			return nil, fmt.Errorf("wildcard route %q must be the tail in pattern %q", part, pattern)
+		}
+		if optional && r.priority != kAnyPattern && r.priority != kRegexPattern {
+			return nil, fmt.Errorf("optional route %q must be \"{name?}\" or \"{name?}regex\" in pattern %q",
+				part, pattern)
+		}
+
+		// Check duplicated name in the same pattern.
+		for _, name := range r.names() {
+			if nameDuplicated[name] {
+				return nil, fmt.Errorf("duplicated route name %q in pattern %q", name, pattern)
 			}
+			nameDuplicated[name] = true
 		}
 
-		r.pattern = part
+		routes = append(routes, r)
+	}
+
+	return routes, nil
+}
+
+// Optional route "{name?}" or "{name?}regex", it can only be followed by the
+// optional ones, e.g. pattern "/archive/{year?}\d{4}/{month?}\d{2}" expands to
+// "/archive", "/archive/{year}\d{4}" and "/archive/{year}\d{4}/{month}\d{2}".
+// Returns the part without "?".
+func trimOptionalRoute(part string) (string, bool) {
+	if !strings.HasPrefix(part, "{") {
+		return part, false
+	}
+	if i := strings.Index(part, "}"); i > 1 && part[i-1] == '?' {
+		return part[:i-1] + part[i:], true
+	}
+	return part, false
+}
+
+// Expand the optional routes, the shortest one first. The routes of each
+// expansion are a prefix of the ones of the pattern.
+func expandOptionalRoutes(routes []*route) [][]*route {
+	expansions := make([][]*route, 0, 1)
+	for i, r := range routes {
+		if r.optional {
+			expansions = append(expansions, routes[:i])
+		}
+	}
+	return append(expansions, routes)
+}
+
+// Parse a part of the pattern to a route, without the position in the tree.
+func parseRoutePart(part string) (*route, error) {
+	r := &route{part: part}
+
+	if isPartialRoute(part) {
+		regex, converters, err := compilePartialRoute(part)
+		if err != nil {
+			return nil, err
+		}
+		r.pattern, r.regex, r.priority = regex.String(), regex, kPartialPattern
+		r.converters = converters
+		return r, nil
+	}
 
-		// Decide the priority.
-		if r.pattern == "" && r.name != "" {
-			r.priority = kAnyPattern
-		} else if r.regex != nil {
-			r.priority = kRegexPattern
+	var isRegex, isWildcard bool
+
+	r.name, r.pattern, isRegex = splitRouteNameAndMatchPattern(part)
+	if strings.HasSuffix(r.name, wildcardSuffix) {
+		r.name, isWildcard = strings.TrimSuffix(r.name, wildcardSuffix), true
+	}
+
+	if isRegex {
+		if r.pattern == "^$" {
+			return nil, fmt.Errorf("empty route (regex) %q", r.pattern)
+		}
+		if regex, err := regexp.Compile(r.pattern); err != nil {
+			return nil, fmt.Errorf("unable to compile route %q, compile error: %s", part, err.Error())
 		} else {
-			r.priority = kAbsolutePattern
+			r.regex = regex
 		}
+	}
 
-		// Bind the handler to the tail.
-		if i == depth-1 {
-			r.handler = handler
+	// Decide the priority.
+	if isWildcard {
+		if r.name == "" || r.pattern != "" {
+			return nil, fmt.Errorf("wildcard route %q must be named without regex", part)
 		}
-		routes = append(routes, r)
+		r.priority = kWildcardPattern
+	} else if r.pattern == "" && r.name != "" {
+		r.priority = kAnyPattern
+	} else if r.regex != nil {
+		r.priority = kRegexPattern
+	} else {
+		r.priority = kAbsolutePattern
 	}
+	return r, nil
+}
 
-	return routes, nil
+// Names of the route variables filled in by the route.
+func (r *route) names() []string {
+	if r.priority == kPartialPattern {
+		names := make([]string, 0)
+		for _, name := range r.regex.SubexpNames() {
+			if name != "" {
+				names = append(names, name)
+			}
+		}
+		return names
+	}
+	if r.name == "" {
+		return nil
+	}
+	return []string{r.name}
 }
 
 // part: "<name>|{name}tomatch"
@@ -631,6 +738,123 @@ func splitRouteNameAndMatchPattern(part string) (name string, tomatch string, is
 	return
 }
 
+// The route variable "{name}" or "{name:regex}" inside a part.
+var routeVarPrefix = regexp.MustCompile(`^\{[A-Za-z_]\w*[:}]`)
+
+// Find the first route variable in `part`. Returns -1 if not found.
+// The regex of the variable may contain braces too, e.g. "{year:\d{4}}".
+func indexRouteVar(part string) (start, end int) {
+	for i := 0; i < len(part); i++ {
+		if part[i] == '\\' {
+			i++
+			continue
+		}
+		if part[i] != '{' || !routeVarPrefix.MatchString(part[i:]) {
+			continue
+		}
+		depth := 0
+		for j := i; j < len(part); j++ {
+			switch part[j] {
+			case '\\':
+				j++
+			case '{':
+				depth++
+			case '}':
+				if depth--; depth == 0 {
+					return i, j + 1
+				}
+			}
+		}
+		break
+	}
+	return -1, -1
+}
+
+// A partial route has route variables inside the part together with literal
+// text, e.g. "{w}x{h}.png", "v{major:\d+}.{minor:\d+}" and "{id:\d+}.json".
+// The regex of a variable followed by text is given as "{name:regex}". Part
+// "{name}regex" is a regex route as a whole, unless more variables follow, e.g.
+// "{file}\w+\.json" of which the variable captures the ".json" too.
+func isPartialRoute(part string) bool {
+	if part == "" || part[0] == '<' {
+		return false
+	}
+	start, end := indexRouteVar(part)
+	if start < 0 {
+		return false
+	}
+	if start > 0 || strings.Contains(part[start:end], ":") {
+		return true
+	}
+	next, _ := indexRouteVar(part[end:])
+	return next >= 0
+}
+
+// A piece of a partial route, either a literal text or a route variable.
+type routePiece struct {
+	name string
+	// The literal text, or the regex of the route variable (may be "").
+	text string
+	// The converter of the route variable, e.g. "int" of "{id:int}", then the
+	// text is the regex of the converter.
+	converter *routeConverter
+}
+
+func splitPartialRoute(part string) []routePiece {
+	pieces := make([]routePiece, 0)
+	for part != "" {
+		start, end := indexRouteVar(part)
+		if start < 0 {
+			pieces = append(pieces, routePiece{text: part})
+			break
+		}
+		if start > 0 {
+			pieces = append(pieces, routePiece{text: part[:start]})
+		}
+		name, regex := part[start+1:end-1], ""
+		if i := strings.Index(name, ":"); i >= 0 {
+			name, regex = name[:i], strings.TrimSuffix(strings.TrimPrefix(name[i+1:], "^"), "$")
+		}
+		piece := routePiece{name: name, text: regex}
+		if c := lookupConverter(regex); c != nil {
+			piece.text, piece.converter = c.Regex, &routeConverter{name, regex, c}
+		}
+		pieces = append(pieces, piece)
+		part = part[end:]
+	}
+	return pieces
+}
+
+// Compile a partial route to a regex capturing the route variables by name.
+// e.g. "{w}x{h:\d+}.png" is compiled to "^(?P<w>.+?)x(?P<h>\d+)\.png$".
+// The regex of a variable with a converter is the one of the converter, e.g.
+// "{id:int}" is compiled to "^(?P<id>-?\d+)$".
+func compilePartialRoute(part string) (*regexp.Regexp, []routeConverter, error) {
+	buf := bytes.NewBufferString("^")
+	var converters []routeConverter
+	for _, piece := range splitPartialRoute(part) {
+		if piece.name == "" {
+			buf.WriteString(regexp.QuoteMeta(piece.text))
+			continue
+		}
+		if piece.converter != nil {
+			converters = append(converters, *piece.converter)
+		}
+		regex := piece.text
+		if regex == "" {
+			regex = ".+?"
+		}
+		fmt.Fprintf(buf, "(?P<%s>%s)", piece.name, regex)
+	}
+	buf.WriteString("$")
+
+	regex, err := regexp.Compile(buf.String())
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to compile route %q, compile error: %s", part, err.Error())
+	}
+	return regex, converters, nil
+}
+
 func isNil(i interface{}) bool {
 	defer func() { recover() }()
 	return i == nil || reflect.ValueOf(i).IsNil()
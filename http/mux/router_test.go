@@ -0,0 +1,583 @@
+package mux
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWildcardRoute(t *testing.T) {
+	rt := NewRouter()
+	for _, pattern := range []string{
+		"/files/{path...}",
+		"/files/{name}",
+		"/files/readme.md",
+		"/files/{name}/raw",
+	} {
+		if err := rt.Handle(pattern, pattern); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expects := map[string][2]string{
+		"/files/readme.md":     {"/files/readme.md", ""},
+		"/files/a.txt":         {"/files/{name}", ""},
+		"/files/a.txt/raw":     {"/files/{name}/raw", ""},
+		"/files/a/b/c.txt":     {"/files/{path...}", "a/b/c.txt"},
+		"/files/a.txt/raw/x/y": {"/files/{path...}", "a.txt/raw/x/y"},
+		"/files/":              {"/files/{path...}", ""},
+	}
+	for path, expect := range expects {
+		mr := rt.Match("GET", path)
+		if mr.Handler != expect[0] || mr.RouteVars["path"] != expect[1] {
+			t.Errorf("%q expects to match %q with path %q, but got %v with %q",
+				path, expect[0], expect[1], mr.Handler, mr.RouteVars["path"])
+		}
+	}
+
+	for _, pattern := range []string{"/{path...}/a", "/{...}", "/{path...}\\d+"} {
+		if err := rt.Handle(pattern, pattern); err == nil {
+			t.Errorf("pattern %q should be invalid", pattern)
+		}
+	}
+}
+
+func TestPartialRoute(t *testing.T) {
+	rt := NewRouter()
+	for _, pattern := range []string{
+		"/reports/{id:\\d+}.json",
+		"/reports/{name}",
+		"/reports/latest.json",
+		"/reports/{file}\\w+\\.json",
+		"/files/{id}\\d+.json",
+		"/img/{w}x{h}.png",
+		"/v{major:\\d+}.{minor:\\d{1,2}}/status",
+	} {
+		if err := rt.Handle(pattern, pattern); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expects := map[string]struct {
+		pattern string
+		vars    RouteVariables
+	}{
+		"/reports/latest.json": {"/reports/latest.json", RouteVariables{}},
+		"/reports/13.json":     {"/reports/{id:\\d+}.json", RouteVariables{"id": "13"}},
+		"/reports/abc.json":    {"/reports/{file}\\w+\\.json", RouteVariables{"file": "abc.json"}},
+		"/files/13.json":       {"/files/{id}\\d+.json", RouteVariables{"id": "13.json"}},
+		"/reports/abc.xml":     {"/reports/{name}", RouteVariables{"name": "abc.xml"}},
+		"/img/100x200.png":     {"/img/{w}x{h}.png", RouteVariables{"w": "100", "h": "200"}},
+		"/v1.12/status":        {"/v{major:\\d+}.{minor:\\d{1,2}}/status", RouteVariables{"major": "1", "minor": "12"}},
+		"/v1.123/status":       {"", nil},
+	}
+	for path, expect := range expects {
+		mr := rt.Match("GET", path)
+		if expect.pattern == "" {
+			if mr.Handler != nil {
+				t.Errorf("%q expects no match, but got %v", path, mr.Handler)
+			}
+			continue
+		}
+		if mr.Handler != expect.pattern || fmt.Sprint(mr.RouteVars) != fmt.Sprint(expect.vars) {
+			t.Errorf("%q expects to match %q with %v, but got %v with %v",
+				path, expect.pattern, expect.vars, mr.Handler, mr.RouteVars)
+		}
+	}
+
+	if err := rt.Handle("/img/{w}x{w}.gif", "dup"); err == nil {
+		t.Errorf("duplicated names in a partial route should be invalid")
+	}
+}
+
+func TestOptionalRoute(t *testing.T) {
+	rt := NewRouter()
+	if err := rt.Handle("/archive/{year?}\\d{4}/{month?}\\d{2}", "archive", WithDefaults("month", "01")); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.HandleMethod("POST", "/archive/{year?}\\d{4}/{month?}\\d{2}", "archive"); err != nil {
+		t.Errorf("binding another method to the optional pattern got %v", err)
+	}
+	if err := rt.Handle("/archive/{year}\\d{4}", "year"); err == nil {
+		t.Errorf("an expansion of the optional pattern should conflict")
+	}
+	if err := rt.Handle("/{page?}\\d+", "page"); err != nil {
+		t.Fatal(err)
+	}
+
+	expects := map[string]string{
+		"/archive":         "map[month:01]",
+		"/archive/2014":    "map[month:01 year:2014]",
+		"/archive/2014/10": "map[month:10 year:2014]",
+		"/":                "map[]",
+		"/3":               "map[page:3]",
+	}
+	for path, vars := range expects {
+		mr := rt.Match("GET", path)
+		if mr.Handler == nil || fmt.Sprint(mr.RouteVars) != vars {
+			t.Errorf("%q expects to match with %s, but got %v with %v", path, vars, mr.Handler, mr.RouteVars)
+		}
+	}
+	if mr := rt.Match("GET", "/archive/14"); mr.Handler != nil {
+		t.Errorf("%q expects no match, but got %v", "/archive/14", mr.Handler)
+	}
+
+	for _, pattern := range []string{"/a/{b?}/c", "/a/{b...?}"} {
+		if err := rt.Handle(pattern, pattern); err == nil {
+			t.Errorf("pattern %q should be invalid", pattern)
+		}
+	}
+}
+
+// A route table of a few hundred patterns, like the one of a REST API.
+func newBenchRouter(b *testing.B) *Router {
+	rt := NewRouter()
+	for i := 0; i < 20; i++ {
+		resource := fmt.Sprintf("resource%02d", i)
+		for _, pattern := range []string{
+			"/api/v1/%s",
+			"/api/v1/%s/",
+			"/api/v1/%s/count",
+			"/api/v1/%s/search",
+			"/api/v1/%s/{id}\\d+",
+			"/api/v1/%s/{id}\\d+/edit",
+			"/api/v1/%s/{id}\\d+/history",
+			"/api/v1/%s/{id}\\d+/members",
+			"/api/v1/%s/{id}\\d+/members/{member}",
+			"/api/v1/%s/{name}",
+			"/api/v1/%s/{name}/raw",
+			"/api/v1/%s/{id:\\d+}.json",
+			"/api/v2/%s",
+			"/api/v2/%s/{id}",
+			"/static/%s/{path...}",
+		} {
+			pattern = fmt.Sprintf(pattern, resource)
+			if err := rt.HandleMethod("GET", pattern, pattern); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	return rt
+}
+
+func BenchmarkRouterMatch(b *testing.B) {
+	rt := newBenchRouter(b)
+	for _, bc := range []struct{ name, path string }{
+		{"Static", "/api/v1/resource10/count"},
+		{"Regex", "/api/v1/resource10/42/members"},
+		{"Any", "/api/v1/resource10/alice/raw"},
+		{"Partial", "/api/v1/resource10/42.json"},
+		{"Wildcard", "/static/resource10/css/site/main.css"},
+		{"NotFound", "/api/v3/resource10"},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			if mr := rt.Match("GET", bc.path); mr.Handler == nil && bc.name != "NotFound" {
+				b.Fatalf("%q not matched", bc.path)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				rt.Match("GET", bc.path)
+			}
+		})
+	}
+}
+
+func TestStaticRouteAllocs(t *testing.T) {
+	rt := NewRouter()
+	for _, pattern := range []string{"/", "/api/users", "/api/users/{id}\\d+", "/api/groups/", "/api/{name}"} {
+		if err := rt.Handle(pattern, pattern); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, path := range []string{"/", "/api/users", "/api/groups/"} {
+		if allocs := testing.AllocsPerRun(100, func() { rt.Match("GET", path) }); allocs != 0 {
+			t.Errorf("%q expects no allocation, but got %v", path, allocs)
+		}
+	}
+}
+
+func TestRouterUpdate(t *testing.T) {
+	rt := NewRouter()
+	if err := rt.Handle("/a", "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	err := rt.Update(func(tx *RouterTx) error {
+		if err := tx.HandleMethod("GET", "/b", "b"); err != nil {
+			return err
+		}
+		if mr := rt.Match("GET", "/b"); mr.Handler != nil {
+			t.Errorf("changes are visible before the update is done")
+		}
+		return tx.HandleMethod("GET", "/a/{name}/", "a/{name}/")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, expect := range map[string]string{"/a": "a", "/b": "b", "/a/x/": "a/{name}/"} {
+		if mr := rt.Match("GET", path); mr.Handler != expect {
+			t.Errorf("%q expects %q, but got %v", path, expect, mr.Handler)
+		}
+	}
+
+	err = rt.Update(func(tx *RouterTx) error {
+		if err := tx.HandleMethod("GET", "/c", "c"); err != nil {
+			return err
+		}
+		return tx.HandleMethod("GET", "/b", "b")
+	})
+	if err == nil {
+		t.Errorf("duplicated pattern expects an error")
+	}
+	if mr := rt.Match("GET", "/c"); mr.Handler != nil {
+		t.Errorf("changes of a failed update expect to be discarded, but got %v", mr.Handler)
+	}
+}
+
+func TestRouterConcurrentUpdate(t *testing.T) {
+	rt := NewRouter()
+	rt.Handle("/static", "static")
+
+	done := make(chan bool)
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			rt.HandleMethod("GET", fmt.Sprintf("/items/%d/{name}", i), i)
+		}
+	}()
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		if mr := rt.Match("GET", "/static"); mr.Handler != "static" {
+			t.Fatalf("/static expects to match while updating, but got %v", mr.Handler)
+		}
+	}
+	if mr := rt.Match("GET", "/items/99/x"); mr.Handler != 99 || mr.RouteVars["name"] != "x" {
+		t.Errorf("/items/99/x expects 99 with name x, but got %v with %v", mr.Handler, mr.RouteVars)
+	}
+}
+
+func TestRouterRemoveAndReplace(t *testing.T) {
+	rt := NewRouter()
+	for _, pattern := range []string{"/a", "/a/b", "/a/b/c", "/a/{x}", "/ab", "/ac"} {
+		if err := rt.Handle(pattern, pattern); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rt.HandleMethod("POST", "/a/b", "POST /a/b")
+
+	if err := rt.Remove("/a/b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.Remove("/ab"); err != nil {
+		t.Fatal(err)
+	}
+	for path, expect := range map[string]interface{}{
+		"/a":     "/a",
+		"/a/b":   "/a/{x}",
+		"/a/b/c": "/a/b/c",
+		"/ab":    nil,
+		"/ac":    "/ac",
+	} {
+		if mr := rt.Match("POST", path); mr.Handler != expect {
+			t.Errorf("%q expects %v after removed, but got %v", path, expect, mr.Handler)
+		}
+	}
+	for _, pattern := range []string{"/a/b", "/a/{y}", "/x"} {
+		if err := rt.Remove(pattern); err == nil {
+			t.Errorf("removing %q expects an error", pattern)
+		}
+	}
+
+	if err := rt.Replace("/a", "new /a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.ReplaceMethod("GET", "/ac", "GET /ac"); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.Replace("/x", "x"); err == nil {
+		t.Errorf("replacing a pattern not registered expects an error")
+	}
+	if mr := rt.Match("GET", "/a"); mr.Handler != "new /a" {
+		t.Errorf("/a expects to be replaced, but got %v", mr.Handler)
+	}
+	if mr := rt.Match("GET", "/ac"); mr.Handler != "GET /ac" {
+		t.Errorf("GET /ac expects to be replaced, but got %v", mr.Handler)
+	}
+	if err := rt.RemoveMethod("GET", "/ac"); err != nil {
+		t.Fatal(err)
+	}
+	if mr := rt.Match("GET", "/ac"); mr.Handler != "/ac" {
+		t.Errorf("GET /ac expects the one of any method, but got %v", mr.Handler)
+	}
+
+	// The empty branches are pruned, and the static ones merged.
+	for _, pattern := range []string{"/a/{x}", "/a/b/c", "/ac"} {
+		if err := rt.Remove(pattern); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if tree := rt.DumpTree(); tree != "/a [*]\n" {
+		t.Errorf("tree expects to be pruned, but got\n%s", tree)
+	}
+}
+
+func TestRouterWalk(t *testing.T) {
+	rt := NewRouter()
+	rt.HandleMethod("GET", "/users/{id}\\d+", "get user", WithName("user"))
+	rt.HandleMethod("PUT", "/users/{id}\\d+", "put user")
+	rt.Handle("/files/{path...}", "files")
+	rt.HandleMethod("GET", "/archive/{year?}\\d{4}", "archive", WithVersion("2.0", ""))
+
+	routes := rt.Routes()
+	if len(routes) != 3 {
+		t.Fatalf("expects 3 routes, but got %d", len(routes))
+	}
+	user := routes[0]
+	if user.Pattern != "/users/{id}\\d+" || fmt.Sprint(user.Methods) != "[GET PUT]" ||
+		fmt.Sprint(user.Vars) != "[id]" || len(user.Handlers) != 2 || user.Handlers[0].Name != "user" {
+		t.Errorf("unexpected route %+v", user)
+	}
+	if part := user.Parts[1]; part.Kind != "regex" || part.Regex != "^\\d+$" {
+		t.Errorf("unexpected part %+v", part)
+	}
+	if part := routes[1].Parts[1]; part.Kind != "wildcard" || routes[1].Methods[0] != "*" {
+		t.Errorf("unexpected part %+v of %v", part, routes[1].Methods)
+	}
+	if part := routes[2].Parts[1]; !part.Optional || part.Part != "{year?}\\d{4}" || routes[2].Handlers[0].Version != ">=2.0" {
+		t.Errorf("unexpected part %+v", part)
+	}
+
+	stop := fmt.Errorf("stop")
+	n := 0
+	err := rt.Walk(func(info RouteInfo) error {
+		if n++; n == 2 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || n != 2 {
+		t.Errorf("walk expects to stop at the error, but got %v after %d", err, n)
+	}
+}
+
+func TestExportRoutes(t *testing.T) {
+	m := NewMux()
+	m.Get("/users/{id}\\d+", textHandler("user"))
+	m.Get("/users/", textHandler("users"))
+	m.Get("/", textHandler("home"), WithHost("{sub}.example.com"))
+
+	buf := new(strings.Builder)
+	if err := ExportJSON(buf, m.Routes()); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{`"pattern": "/users/{id}\\d+"`, `"kind": "regex"`, `"host": "{sub}.example.com"`} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("JSON expects to contain %s, but got\n%s", s, buf)
+		}
+	}
+
+	buf.Reset()
+	if err := ExportDOT(buf, m.Routes()); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		"digraph routes {",
+		`n2 [label="{id}\\d+\nGET", shape=box];`,
+		`n3 [label="/\nGET", shape=box];`,
+		`n4 [label="{sub}.example.com/\nGET", shape=box];`,
+		"n0 -> n1;\n\tn1 -> n2;\n\tn1 -> n3;",
+	} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("DOT expects to contain %s, but got\n%s", s, buf)
+		}
+	}
+}
+
+type recordT struct{ errors []string }
+
+func (t *recordT) Helper() {}
+func (t *recordT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func TestAnalyzeConflicts(t *testing.T) {
+	rt := NewRouter()
+	rt.HandleMethod("GET", "/users/{id}", "user")
+	rt.HandleMethod("POST", "/users/new", "new user")
+	rt.HandleMethod("GET", "/files/{id}\\d+", "file by id")
+	rt.HandleMethod("GET", "/files/{hex}[0-9a-f]+", "file by hex")
+	rt.HandleMethod("GET", "/tags/{name}\\w+\\.json", "tag")
+	rt.HandleMethod("GET", "/tags/{name:\\w+}.json", "tag by partial")
+	rt.HandleMethod("GET", "/docs/{path...}", "docs")
+	rt.HandleMethod("GET", "/about", "about")
+
+	conflicts := make(map[string]Conflict)
+	for _, c := range rt.Analyze() {
+		conflicts[string(c.Kind)+" "+c.Pattern] = c
+	}
+	testcases := []Conflict{
+		{Kind: ConflictShadowed, Pattern: "/users/{id}", Winner: "/users/new", Path: "/users/new"},
+		{Kind: ConflictAmbiguous, Pattern: "/files/{hex}[0-9a-f]+", Winner: "/files/{id}\\d+", Path: "/files/0"},
+		{Kind: ConflictUnreachable, Pattern: "/tags/{name}\\w+\\.json", Winner: "/tags/{name:\\w+}.json"},
+		{Kind: ConflictShadowed, Pattern: "/tags/{name}\\w+\\.json", Winner: "/tags/{name:\\w+}.json"},
+	}
+	for _, tc := range testcases {
+		c, ok := conflicts[string(tc.Kind)+" "+tc.Pattern]
+		if !ok || c.Winner != tc.Winner || (tc.Path != "" && c.Path != tc.Path) {
+			t.Errorf("expects conflict %v, but got %v", tc, c)
+		}
+		delete(conflicts, string(tc.Kind)+" "+tc.Pattern)
+	}
+	for _, c := range conflicts {
+		t.Errorf("unexpected conflict %v", c)
+	}
+
+	rec := new(recordT)
+	AssertNoConflicts(rec, rt, ConflictUnreachable)
+	if len(rec.errors) != 1 || !strings.Contains(rec.errors[0], "unreachable") {
+		t.Errorf("expects one unreachable conflict, but got %v", rec.errors)
+	}
+	rt.Remove("/tags/{name}\\w+\\.json")
+	rt.Remove("/files/{hex}[0-9a-f]+")
+	rec = new(recordT)
+	AssertNoConflicts(rec, rt, ConflictAmbiguous, ConflictUnreachable)
+	if len(rec.errors) != 0 {
+		t.Errorf("expects no conflicts, but got %v", rec.errors)
+	}
+	AssertNoConflicts(rec, rt)
+	if len(rec.errors) != 1 {
+		t.Errorf("expects the shadowed conflict, but got %v", rec.errors)
+	}
+}
+
+func TestRouterExplain(t *testing.T) {
+	rt := NewRouter()
+	rt.Handle("/users/{id}\\d+/posts", "posts")
+	rt.Handle("/users/{name}/profile", "profile")
+	rt.Handle("/users/", "users")
+
+	x := rt.Explain("/users/ggicci/profile")
+	if x.Pattern != "/users/{name}/profile" || x.RouteVars["name"] != "ggicci" {
+		t.Errorf("expects to match the profile, but got\n%s", x)
+	}
+	steps := make([]string, 0)
+	for _, s := range x.Steps {
+		steps = append(steps, s.Action+" "+s.Node)
+	}
+	expected := "match /users/,mismatch {id}\\d+,match {name},match /profile,leaf /profile"
+	if got := strings.Join(steps, ","); got != expected {
+		t.Errorf("expects steps %s, but got %s\n%s", expected, got, x)
+	}
+
+	x = rt.Explain("/users/12/profile")
+	if x.Pattern != "/users/{name}/profile" || !strings.Contains(x.String(), "backtrack {id}\\d+ on \"12\"") {
+		t.Errorf("expects to backtrack from {id}, but got\n%s", x)
+	}
+
+	x = rt.Explain("/users/12/comments")
+	if x.Pattern != "" || len(x.HandlersOnTheWay) != 1 || x.HandlersOnTheWay[0].Pattern != "/users/" {
+		t.Errorf("expects no match with \"/users/\" on the way, but got\n%s", x)
+	}
+	if summary := x.Summary(); !strings.HasPrefix(summary, "no match") || !strings.Contains(summary, `"/users/" on the way`) {
+		t.Errorf("unexpected summary %s", summary)
+	}
+}
+
+func TestRouterIgnoreCase(t *testing.T) {
+	rt := NewRouter(IgnoreCase(), NormalizeUnicode())
+	rt.Handle("/Users/{name}", "user")
+	rt.Handle("/users/{name}/Posts/{id}\\d+", "posts")
+	rt.Handle("/caf\u00e9/{name}[A-Z]+", "cafe")
+	rt.Handle("/files/", "files")
+
+	cases := []struct {
+		path, pattern, canonical string
+		vars                     RouteVariables
+	}{
+		{"/Users/Ggicci", "/Users/{name}", "", RouteVariables{"name": "Ggicci"}},
+		{"/USERS/Ggicci", "/Users/{name}", "/Users/Ggicci", RouteVariables{"name": "Ggicci"}},
+		{"/USERS/ggicci/posts/12", "/users/{name}/Posts/{id}\\d+", "/users/ggicci/Posts/12", RouteVariables{"name": "ggicci", "id": "12"}},
+		{"/CAF\u00c9/ABC", "/caf\u00e9/{name}[A-Z]+", "/caf\u00e9/ABC", RouteVariables{"name": "ABC"}},
+		{"/CAFE\u0301/ABC", "/caf\u00e9/{name}[A-Z]+", "/caf\u00e9/ABC", RouteVariables{"name": "ABC"}},
+		{"/cafe\u0301/abc", "", "", nil},
+	}
+	for _, c := range cases {
+		mr := rt.Match("GET", c.path)
+		if mr.Pattern != c.pattern || mr.CanonicalPath != c.canonical || fmt.Sprint(mr.RouteVars) != fmt.Sprint(c.vars) {
+			t.Errorf("%q expects %q %q %v, but got %q %q %v", c.path, c.pattern, c.canonical, c.vars,
+				mr.Pattern, mr.CanonicalPath, mr.RouteVars)
+		}
+	}
+	if mr := rt.Match("GET", "/FILES/a"); len(mr.HandlersOnTheWay) != 1 || mr.HandlersOnTheWay[0].Pattern != "/files/" {
+		t.Errorf("expects \"/files/\" on the way, but got %v", mr.HandlersOnTheWay)
+	}
+	if err := rt.Handle("/USERS/{id}", "conflict"); err == nil {
+		t.Errorf("expects a conflict of patterns differing in case")
+	}
+	if !rt.PatternExists("/Users/{name}") || rt.PatternExists("/users/{name}") {
+		t.Errorf("expects the pattern to exist as registered")
+	}
+	if mr := NewRouter().Match("GET", "/users/x"); mr.Handler != nil {
+		t.Errorf("expects no match")
+	}
+}
+
+func TestRouteConverters(t *testing.T) {
+	if lookupConverter("hex") == nil {
+		RegisterConverter("hex", Converter{Regex: `[0-9a-f]+`, Convert: func(s string) (interface{}, error) {
+			return strconv.ParseUint(s, 16, 64)
+		}})
+	}
+
+	rt := NewRouter()
+	rt.Handle("/users/{id:int}", "user")
+	rt.Handle("/users/{name}", "user by name")
+	rt.Handle("/orders/{id:uuid}", "order")
+	rt.Handle("/logs/{day:date}.log", "log")
+	rt.Handle("/posts/{title:slug}", "post")
+	rt.Handle("/colors/{rgb:hex}", "color")
+
+	cases := []struct {
+		path, pattern string
+		value         interface{}
+	}{
+		{"/users/12", "/users/{id:int}", 12},
+		{"/users/-3", "/users/{id:int}", -3},
+		{"/users/99999999999999999999", "/users/{name}", nil},
+		{"/orders/123E4567-E89B-12D3-A456-426614174000", "/orders/{id:uuid}", "123e4567-e89b-12d3-a456-426614174000"},
+		{"/orders/123", "", nil},
+		{"/logs/2024-02-29.log", "/logs/{day:date}.log", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"/logs/2023-02-29.log", "", nil},
+		{"/posts/hello-world-2", "/posts/{title:slug}", "hello-world-2"},
+		{"/posts/Hello", "", nil},
+		{"/colors/ff00ff", "/colors/{rgb:hex}", uint64(0xff00ff)},
+	}
+	for _, c := range cases {
+		mr := rt.Match("GET", c.path)
+		var value interface{}
+		for _, v := range mr.RouteValues {
+			value = v
+		}
+		if mr.Pattern != c.pattern || fmt.Sprint(value) != fmt.Sprint(c.value) {
+			t.Errorf("%q expects %q with %v, but got %q with %v", c.path, c.pattern, c.value, mr.Pattern, mr.RouteValues)
+		}
+	}
+	if mr := rt.Match("GET", "/users/12"); mr.RouteVars["id"] != "12" {
+		t.Errorf("expects the raw value next to the typed one, but got %v", mr.RouteVars)
+	}
+
+	// Same regex, different converters.
+	if err := rt.Handle("/colors/{code:[0-9a-f]+}", "code"); err != nil {
+		t.Errorf("expects no conflict, but got %v", err)
+	}
+	if part := rt.Routes()[0].Parts[1]; part.Converters["id"] != "int" {
+		t.Errorf("unexpected part %+v", part)
+	}
+}
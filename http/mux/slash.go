@@ -0,0 +1,51 @@
+package mux
+
+import (
+	"net/http"
+	"strings"
+)
+
+// How `Mux` treats a path which only matches with or without the trailing "/".
+type SlashPolicy int
+
+const (
+	// "/a/b" redirects to "/a/b/" if only "/a/b/" exists, "/a/b/" never
+	// redirects to "/a/b". The default.
+	RedirectToSlash SlashPolicy = iota
+	// "/a/b/" redirects to "/a/b" if only "/a/b" exists, "/a/b" never
+	// redirects to "/a/b/".
+	RedirectToNoSlash
+	// Never redirect, "/a/b" and "/a/b/" are different paths.
+	StrictSlash
+	// Serve "/a/b" and "/a/b/" by the same handler, whichever exists, without
+	// redirecting.
+	IgnoreSlash
+)
+
+// Redirect to the path with the query kept. The code is the one by default,
+// see `Mux.RedirectCode`.
+func (m *Mux) redirect(r *http.Request, p string, code int) http.Handler {
+	if m.RedirectCode != 0 {
+		code = m.RedirectCode
+	}
+	url := *r.URL
+	url.Path, url.RawPath = p, ""
+	return http.RedirectHandler(url.String(), code)
+}
+
+// Find the handler of the path without the trailing "/", which redirects to it
+// or serves it according to the slash policy. Returns nil if none.
+func (m *Mux) noSlashHandler(r *http.Request, method, np string) (http.Handler, *routeContext) {
+	if (m.SlashPolicy != RedirectToNoSlash && m.SlashPolicy != IgnoreSlash) ||
+		np == "/" || !strings.HasSuffix(np, "/") {
+		return nil, nil
+	}
+	mr := m.match(r, method, strings.TrimSuffix(np, "/"))
+	if mr.Handler == nil {
+		return nil, nil
+	}
+	if m.SlashPolicy == RedirectToNoSlash {
+		return m.redirect(r, strings.TrimSuffix(r.URL.Path, "/"), http.StatusFound), nil
+	}
+	return mr.Handler.(http.Handler), mr.route()
+}
@@ -0,0 +1,231 @@
+package mux
+
+import (
+	"fmt"
+	"strings"
+)
+
+// An immutable snapshot of the routes of `Router`. A change copies the nodes
+// on its way and publishes a new snapshot, the rest of the tree is shared.
+type routeTable struct {
+	root *node
+	// In the order registered.
+	endpoints []*endpoint
+}
+
+var emptyRouteTable = &routeTable{root: &node{}}
+
+func (rt *Router) load() *routeTable {
+	if t, ok := rt.table.Load().(*routeTable); ok {
+		return t
+	}
+	return emptyRouteTable
+}
+
+// Apply the changes made in fn at once. Matching sees either none or all of
+// them, and none if fn returns an error. e.g.
+//
+//	err := rt.Update(func(tx *RouterTx) error {
+//		if err := tx.HandleMethod("GET", "/beta/users", listUsers); err != nil {
+//			return err
+//		}
+//		return tx.HandleMethod("POST", "/beta/users", createUser)
+//	})
+//
+// The changes are serialized. Don't call the methods of `Router` changing the
+// routes in fn, which deadlocks.
+func (rt *Router) Update(fn func(tx *RouterTx) error) error {
+	rt.mutex.Lock()
+	defer rt.mutex.Unlock()
+
+	t := rt.load()
+	tx := &RouterTx{root: t.root, endpoints: t.endpoints, options: rt.options}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	rt.table.Store(&routeTable{root: tx.root, endpoints: tx.endpoints})
+	return nil
+}
+
+// A batch of changes to the routes of `Router`, see `Router.Update`.
+// It's only valid in the function given to `Router.Update`.
+type RouterTx struct {
+	root      *node
+	endpoints []*endpoint
+	// Whether `endpoints` has been copied from the snapshot.
+	copied  bool
+	options pathOptions
+}
+
+func (tx *RouterTx) Handle(pattern string, handler interface{}, opts ...RouteOption) error {
+	return tx.HandleMethod("", pattern, handler, opts...)
+}
+
+// See `Router.HandleMethod`.
+func (tx *RouterTx) HandleMethod(method, pattern string, handler interface{}, opts ...RouteOption) error {
+	b, err := newBinding(handler, opts)
+	if err != nil {
+		return err
+	}
+	method = strings.ToUpper(strings.TrimSpace(method))
+	pattern = tx.options.normalize(strings.TrimSpace(pattern))
+
+	// Make routes from the pattern.
+	routes, err := makeRoutes(pattern)
+	if err != nil {
+		return err
+	}
+
+	// Bind to the endpoint if there already exists a same pattern. A pattern
+	// with optional routes is checked against each of its expansions.
+	for _, expansion := range expandOptionalRoutes(routes) {
+		l := tx.root.find(tx.options.tokenize(expansion))
+		if l == nil {
+			continue
+		}
+		if l.endpoint.pattern != pattern {
+			return fmt.Errorf("pattern %q conflicts with %q", pattern, l.endpoint.pattern)
+		}
+		ep, err := l.endpoint.bind(method, b)
+		if err != nil {
+			return err
+		}
+		tx.replaceEndpoint(l.endpoint, ep)
+		return nil
+	}
+
+	tx.addEndpoint(&endpoint{pattern: pattern, routes: routes, handlers: map[string][]*binding{method: {b}}})
+	return nil
+}
+
+// See `Router.Remove`.
+func (tx *RouterTx) Remove(pattern string) error {
+	ep, err := tx.lookupEndpoint(pattern)
+	if err != nil {
+		return err
+	}
+	tx.replaceEndpoint(ep, nil)
+	return nil
+}
+
+// See `Router.RemoveMethod`.
+func (tx *RouterTx) RemoveMethod(method, pattern string) error {
+	method = strings.ToUpper(strings.TrimSpace(method))
+	ep, err := tx.lookupEndpoint(pattern)
+	if err != nil {
+		return err
+	}
+	if len(ep.handlers[method]) == 0 {
+		return fmt.Errorf("pattern %q not found", methodPattern(method, ep.pattern))
+	}
+	if len(ep.handlers) == 1 {
+		tx.replaceEndpoint(ep, nil)
+		return nil
+	}
+	handlers := make(map[string][]*binding, len(ep.handlers))
+	for m, bindings := range ep.handlers {
+		if m != method {
+			handlers[m] = bindings
+		}
+	}
+	tx.replaceEndpoint(ep, &endpoint{pattern: ep.pattern, routes: ep.routes, handlers: handlers})
+	return nil
+}
+
+// See `Router.Replace`.
+func (tx *RouterTx) Replace(pattern string, handler interface{}, opts ...RouteOption) error {
+	b, err := newBinding(handler, opts)
+	if err != nil {
+		return err
+	}
+	ep, err := tx.lookupEndpoint(pattern)
+	if err != nil {
+		return err
+	}
+	tx.replaceEndpoint(ep, &endpoint{pattern: ep.pattern, routes: ep.routes, handlers: map[string][]*binding{"": {b}}})
+	return nil
+}
+
+// See `Router.ReplaceMethod`.
+func (tx *RouterTx) ReplaceMethod(method, pattern string, handler interface{}, opts ...RouteOption) error {
+	b, err := newBinding(handler, opts)
+	if err != nil {
+		return err
+	}
+	method = strings.ToUpper(strings.TrimSpace(method))
+	ep, err := tx.lookupEndpoint(pattern)
+	if err != nil {
+		return err
+	}
+	handlers := make(map[string][]*binding, len(ep.handlers)+1)
+	for m, bindings := range ep.handlers {
+		handlers[m] = bindings
+	}
+	handlers[method] = []*binding{b}
+	tx.replaceEndpoint(ep, &endpoint{pattern: ep.pattern, routes: ep.routes, handlers: handlers})
+	return nil
+}
+
+func newBinding(handler interface{}, opts []RouteOption) (*binding, error) {
+	if isNil(handler) {
+		return nil, fmt.Errorf("nil handler")
+	}
+	return &binding{handler: handler, options: newRouteOptions(opts)}, nil
+}
+
+// Find the endpoint of the pattern, which must be the same as registered.
+func (tx *RouterTx) lookupEndpoint(pattern string) (*endpoint, error) {
+	pattern = tx.options.normalize(strings.TrimSpace(pattern))
+	routes, err := makeRoutes(pattern)
+	if err != nil {
+		return nil, err
+	}
+	l := tx.root.find(tx.options.tokenize(routes))
+	if l == nil || l.endpoint.pattern != pattern {
+		return nil, fmt.Errorf("pattern %q not found", pattern)
+	}
+	return l.endpoint, nil
+}
+
+// Copy the endpoints before changing them, they're shared with the snapshot.
+func (tx *RouterTx) copyEndpoints() {
+	if !tx.copied {
+		tx.endpoints = append([]*endpoint(nil), tx.endpoints...)
+		tx.copied = true
+	}
+}
+
+func (tx *RouterTx) addEndpoint(ep *endpoint) {
+	tx.copyEndpoints()
+	tx.endpoints = append(tx.endpoints, ep)
+	tx.setLeaves(ep, ep)
+}
+
+// Replace the old endpoint with the new one, or remove it if the new one is nil.
+func (tx *RouterTx) replaceEndpoint(old, ep *endpoint) {
+	tx.copyEndpoints()
+	for i, x := range tx.endpoints {
+		if x != old {
+			continue
+		}
+		if ep != nil {
+			tx.endpoints[i] = ep
+		} else {
+			tx.endpoints = append(tx.endpoints[:i], tx.endpoints[i+1:]...)
+		}
+		break
+	}
+	tx.setLeaves(old, ep)
+}
+
+// Set the leaves of each expansion of the old endpoint to the new one, or
+// remove them if the new one is nil.
+func (tx *RouterTx) setLeaves(old, ep *endpoint) {
+	for _, expansion := range expandOptionalRoutes(old.routes) {
+		var l *leaf
+		if ep != nil {
+			l = newLeaf(expansion, ep)
+		}
+		tx.root = tx.root.set(tx.options.tokenize(expansion), l)
+	}
+}
@@ -0,0 +1,450 @@
+package mux
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// A node of the radix tree of `Router`. The static text of the patterns is
+// compressed, e.g. "/api/users" and "/api/groups" share the node "/api/", while
+// a dynamic node matches a whole part of the path following "/", see `route`.
+//
+// The children are tried in priority order, i.e. the static one first, then the
+// dynamic ones ordered by `byRoutePriority`, backtracking when the path isn't
+// fully matched, see `lookup`.
+type node struct {
+	// The static text, "" for the dynamic nodes.
+	prefix string
+	// The route of the dynamic node, nil for the static ones.
+	route *route
+
+	// First bytes of the static children, one for each.
+	indices string
+	static  []*node
+	// Dynamic children, which only follow a "/".
+	dynamic []*node
+
+	// Set if a pattern ends at the node.
+	leaf *leaf
+}
+
+// A pattern (or an expansion of it, see `expandOptionalRoutes`) ends at the leaf.
+type leaf struct {
+	endpoint *endpoint
+	routes   []*route
+	// Whether any of the routes fills in the route variables.
+	hasVars bool
+	// Whether any of the routes has converters, see `Converter`.
+	hasValues bool
+}
+
+func newLeaf(routes []*route, ep *endpoint) *leaf {
+	l := &leaf{endpoint: ep, routes: routes}
+	for _, r := range routes {
+		if len(r.names()) > 0 {
+			l.hasVars = true
+		}
+		if len(r.converters) > 0 {
+			l.hasValues = true
+		}
+	}
+	return l
+}
+
+// Extract the route variables from the path matched by the leaf.
+func (l *leaf) vars(path string) RouteVariables {
+	if !l.hasVars {
+		return nil
+	}
+	rvs := make(RouteVariables)
+	l.eachPart(path, func(r *route, part string) {
+		if r.priority == kWildcardPattern {
+			rvs[r.name] = part
+		} else {
+			r.fill(part, rvs)
+		}
+	})
+	return rvs
+}
+
+// Convert the route variables with converters of the path matched by the leaf.
+func (l *leaf) values(path string) RouteValues {
+	if !l.hasValues {
+		return nil
+	}
+	values := make(RouteValues)
+	l.eachPart(path, func(r *route, part string) {
+		if len(r.converters) > 0 {
+			r.convert(part, values)
+		}
+	})
+	return values
+}
+
+// Call fn with each route and the part of the path it matches, the wildcard
+// route matches the rest of the path.
+func (l *leaf) eachPart(path string, fn func(r *route, part string)) {
+	rest := strings.TrimPrefix(path, "/")
+	for _, r := range l.routes {
+		if r.priority == kWildcardPattern {
+			fn(r, rest)
+			break
+		}
+		part := rest
+		if i := strings.IndexByte(rest, '/'); i >= 0 {
+			part, rest = rest[:i], rest[i+1:]
+		} else {
+			rest = ""
+		}
+		fn(r, part)
+	}
+}
+
+func (n *node) child(c byte) *node {
+	for i := 0; i < len(n.indices); i++ {
+		if n.indices[i] == c {
+			return n.static[i]
+		}
+	}
+	return nil
+}
+
+// A piece of the routes in the tree, either the static text or a dynamic route.
+type token struct {
+	text  string
+	route *route
+}
+
+// Split the routes to tokens. Absolute routes are static text, e.g. routes
+// of "/users/{id}/posts" are "/users/", "{id}" and "/posts".
+func tokenize(routes []*route) []token {
+	tokens := make([]token, 0, len(routes)+1)
+	text := "/"
+	for i, r := range routes {
+		if r.priority == kAbsolutePattern {
+			text += r.pattern
+		} else {
+			tokens = append(tokens, token{text: text}, token{route: r})
+			text = ""
+		}
+		if i < len(routes)-1 {
+			text += "/"
+		}
+	}
+	if text != "" {
+		tokens = append(tokens, token{text: text})
+	}
+	return tokens
+}
+
+// Copy the node to change it, the children are shared.
+func (n *node) clone() *node {
+	c := *n
+	c.static = append([]*node(nil), n.static...)
+	c.dynamic = append([]*node(nil), n.dynamic...)
+	return &c
+}
+
+// Set the leaf at the end of the tokens. Returns the copy of the node with the
+// nodes on the way copied, the node itself is left unchanged. A nil leaf
+// removes the one there, the nodes left empty are pruned, see `setChild`.
+func (n *node) set(tokens []token, l *leaf) *node {
+	n = n.clone()
+	if len(tokens) == 0 {
+		n.leaf = l
+		return n
+	}
+
+	tk, rest := tokens[0], tokens[1:]
+	if tk.route != nil {
+		for i, c := range n.dynamic {
+			if c.route.equal(tk.route) {
+				n.setChild(i, c.set(rest, l))
+				return n
+			}
+		}
+		if l == nil {
+			return n
+		}
+		n.dynamic = append(n.dynamic, (&node{route: tk.route}).set(rest, l))
+		sort.Stable(byRoutePriority(n.dynamic))
+		return n
+	}
+
+	i := strings.IndexByte(n.indices, tk.text[0])
+	if i < 0 && l == nil {
+		return n
+	}
+	if i < 0 {
+		n.indices += tk.text[:1]
+		n.static = append(n.static, (&node{prefix: tk.text}).set(rest, l))
+		return n
+	}
+
+	// Split the child at the longest common prefix.
+	c, j := n.static[i], 0
+	for j < len(tk.text) && j < len(c.prefix) && tk.text[j] == c.prefix[j] {
+		j++
+	}
+	if j < len(c.prefix) {
+		tail := *c
+		tail.prefix = c.prefix[j:]
+		c = &node{prefix: c.prefix[:j], indices: tail.prefix[:1], static: []*node{&tail}}
+	}
+	if j < len(tk.text) {
+		rest = append([]token{{text: tk.text[j:]}}, rest...)
+	}
+	n.setChild(i, c.set(rest, l))
+	return n
+}
+
+// Replace the i-th static (or dynamic) child of the copied node with c. Prune
+// c if it's empty, or merge it with its only child if it's a static one.
+func (n *node) setChild(i int, c *node) {
+	empty := c.leaf == nil && len(c.static) == 0 && len(c.dynamic) == 0
+	if c.route != nil {
+		if empty {
+			n.dynamic = append(n.dynamic[:i], n.dynamic[i+1:]...)
+		} else {
+			n.dynamic[i] = c
+		}
+		return
+	}
+
+	if empty {
+		n.indices = n.indices[:i] + n.indices[i+1:]
+		n.static = append(n.static[:i], n.static[i+1:]...)
+		return
+	}
+	if c.leaf == nil && len(c.dynamic) == 0 && len(c.static) == 1 {
+		merged := *c.static[0]
+		merged.prefix = c.prefix + merged.prefix
+		c = &merged
+	}
+	n.static[i] = c
+}
+
+// Find the leaf the tokens of the routes end at, regardless of the names of
+// the routes.
+func (n *node) find(tokens []token) *leaf {
+	for _, tk := range tokens {
+		if tk.route != nil {
+			var next *node
+			for _, c := range n.dynamic {
+				if c.route.equal(tk.route) {
+					next = c
+					break
+				}
+			}
+			if next == nil {
+				return nil
+			}
+			n = next
+			continue
+		}
+		for text := tk.text; text != ""; {
+			c := n.child(text[0])
+			if c == nil || !strings.HasPrefix(text, c.prefix) {
+				return nil
+			}
+			n, text = c, text[len(c.prefix):]
+		}
+	}
+	return n.leaf
+}
+
+// Find the leaf which matches the full path, path[:i] has been matched by the
+// node. The static text is compared with the key, which is indexed as the
+// path. The static child is tried first, then the dynamic ones in order, the
+// first leaf found wins. The handlers on the way are recorded in t if not nil,
+// and the steps in e if not nil, see `Router.Explain`.
+func (n *node) lookup(path, key string, i int, t *traceTable, e *explainer) *leaf {
+	if t != nil {
+		t.visit(n, path, i)
+	}
+	if i == len(path) && n.leaf != nil {
+		if e != nil {
+			e.step(n, "", "leaf", fmt.Sprintf("pattern %q ends here", n.leaf.endpoint.pattern))
+		}
+		return n.leaf
+	}
+
+	if i < len(path) {
+		if c := n.child(key[i]); c != nil {
+			if t != nil {
+				t.visitPrefix(c, path, key, i)
+			}
+			matched := strings.HasPrefix(key[i:], c.prefix)
+			if e != nil {
+				e.try(c, path[i:], matched)
+			}
+			if matched {
+				if l := c.lookup(path, key, i+len(c.prefix), t, e.enter()); l != nil {
+					return l
+				}
+				e.backtrack(c, path[i:])
+			}
+		}
+	}
+
+	if len(n.dynamic) == 0 {
+		return nil
+	}
+	end := len(path)
+	if j := strings.IndexByte(path[i:], '/'); j >= 0 {
+		end = i + j
+	}
+	for _, c := range n.dynamic {
+		matched := c.route.match(path[i:end])
+		if e != nil {
+			segment := path[i:end]
+			if c.route.priority == kWildcardPattern {
+				segment = path[i:]
+			}
+			e.try(c, segment, matched)
+		}
+		if !matched {
+			continue
+		}
+		// The wildcard route captures the rest of the path, and it's always a tail.
+		if c.route.priority == kWildcardPattern {
+			if t != nil {
+				t.visit(c, path, len(path))
+			}
+			if e != nil && c.leaf != nil {
+				e.enter().step(c, "", "leaf", fmt.Sprintf("pattern %q ends here", c.leaf.endpoint.pattern))
+			}
+			return c.leaf
+		}
+		if l := c.lookup(path, key, end, t, e.enter()); l != nil {
+			return l
+		}
+		e.backtrack(c, path[i:end])
+	}
+	return nil
+}
+
+// Sorted by priority and other policies, like pattern length and pattern
+// alphabetic order.
+// ## Why I sort the routes?
+// For instance, pattern "/cpp/19911110/{file}\\w+?\.cxx" and "/cpp/{date}\\d{8}/born.cxx"
+// both can match path "/cpp/19911110/born.txt", but the former has a higher priority,
+// so the handler bound to which will be returned.
+type byRoutePriority []*node
+
+func (b byRoutePriority) Len() int      { return len(b) }
+func (b byRoutePriority) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b byRoutePriority) Less(i, j int) bool {
+	x, y := b[i].route, b[j].route
+	if x.priority != y.priority {
+		return x.priority > y.priority
+	}
+	if len(x.pattern) != len(y.pattern) {
+		return len(x.pattern) < len(y.pattern)
+	}
+	return x.pattern < y.pattern
+}
+
+// The leaves matched on the way of a path, i.e. the parts "/a", "/a/" and
+// "/a/b" of path "/a/b/c". A leaf followed by a "/" is recorded as the one of
+// the path ending with "/", i.e. "/a" records "/a/" if pattern "/a/" exists.
+// So does the "any" tail, i.e. "/a" records "/a/" of pattern "/a/{name}".
+// The first leaf recorded for a path wins.
+type traceTable []traceItem
+
+type traceItem struct {
+	path string
+	leaf *leaf
+}
+
+func (t *traceTable) record(path string, l *leaf) {
+	if l == nil {
+		return
+	}
+	for _, item := range *t {
+		if item.path == path {
+			return
+		}
+	}
+	*t = append(*t, traceItem{path, l})
+}
+
+// The node matched path[:i]. Record it if a part ends at i, together with the
+// leaf following "/". The root is never recorded.
+func (t *traceTable) visit(n *node, path string, i int) {
+	if i <= 1 || (i < len(path) && path[i] != '/') {
+		return
+	}
+	t.record(path[:i], n.leaf)
+	if path[i-1] == '/' {
+		return
+	}
+	if c := n.child('/'); c != nil && c.prefix == "/" {
+		t.record(path[:i]+"/", c.tail())
+	}
+}
+
+// The static child c of a node which matched path[:i]. Record the leaf
+// following "/" if a part ends right before the last "/" of c, which the path
+// may not reach, e.g. path "/a" with pattern "/a/".
+func (t *traceTable) visitPrefix(c *node, path, key string, i int) {
+	j := i + len(c.prefix) - 1
+	if len(c.prefix) < 2 || c.prefix[len(c.prefix)-1] != '/' || j > len(path) {
+		return
+	}
+	if key[i:j] != c.prefix[:len(c.prefix)-1] || (j < len(path) && path[j] != '/') {
+		return
+	}
+	t.record(path[:j]+"/", c.tail())
+}
+
+// The leaf of the node following "/", or the one of the "any" tail if it's
+// the first child, i.e. the only routes following "/" are "any" or wildcard.
+func (n *node) tail() *leaf {
+	if n.leaf != nil || len(n.static) > 0 || len(n.dynamic) == 0 {
+		return n.leaf
+	}
+	c := n.dynamic[0]
+	if len(c.static) > 0 || len(c.dynamic) > 0 {
+		return nil
+	}
+	if c.route.priority == kAnyPattern || c.route.priority == kWildcardPattern {
+		return c.leaf
+	}
+	return nil
+}
+
+func (t traceTable) Len() int           { return len(t) }
+func (t traceTable) Swap(i, j int)      { t[i], t[j] = t[j], t[i] }
+func (t traceTable) Less(i, j int) bool { return len(t[i].path) < len(t[j].path) }
+
+func (n *node) String() string {
+	str := n.prefix
+	if n.route != nil {
+		str = n.route.String()
+	}
+	if n.leaf != nil {
+		str += fmt.Sprintf(" [%s]", n.leaf.endpoint)
+	}
+	return str
+}
+
+func (n *node) dump(buf *bytes.Buffer, indent, branch string) {
+	fmt.Fprintf(buf, "%s%s%v\n", indent, branch, n)
+	switch branch {
+	case "├── ":
+		indent += "│   "
+	case "└── ":
+		indent += "    "
+	}
+	children := append(append([]*node(nil), n.static...), n.dynamic...)
+	for i, c := range children {
+		if i == len(children)-1 {
+			c.dump(buf, indent, "└── ")
+		} else {
+			c.dump(buf, indent, "├── ")
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package mux
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Build the URL path of the route named `name`, with the route variables given
+// in pairs, e.g. mux.URL("user.show", "id", "42") gets "/users/42" if the route
+// is registered as mux.Get("/users/{id}\\d+", h, WithName("user.show")).
+// Returns an error if any variable is missing or doesn't match its route.
+func (m *Mux) URL(name string, pairs ...string) (string, error) {
+	m.namesMutex.RLock()
+	nr, ok := m.names[name]
+	m.namesMutex.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("route %q not found", name)
+	}
+	if len(pairs)%2 != 0 {
+		return "", fmt.Errorf("odd number of route variables %q for route %q", pairs, name)
+	}
+	vars := make(map[string]string, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		vars[pairs[i]] = pairs[i+1]
+	}
+	return buildPath(nr.pattern, vars)
+}
+
+// Fill in the pattern with the route variables.
+func buildPath(pattern string, vars map[string]string) (string, error) {
+	if pattern == "/" {
+		return pattern, nil
+	}
+
+	parts := strings.Split(pattern[1:], "/")
+	for i, part := range parts {
+		// Leave out the optional route absent, and the ones following it.
+		part, optional := trimOptionalRoute(part)
+		if optional {
+			if name, _, _ := splitRouteNameAndMatchPattern(part); vars[name] == "" {
+				return "/" + strings.Join(parts[:i], "/"), nil
+			}
+		}
+
+		if isPartialRoute(part) {
+			segment, err := buildPartialRoute(part, vars)
+			if err != nil {
+				return "", fmt.Errorf("%s in pattern %q", err.Error(), pattern)
+			}
+			parts[i] = segment
+			continue
+		}
+
+		name, tomatch, isRegex := splitRouteNameAndMatchPattern(part)
+		if name == "" {
+			continue
+		}
+
+		// The wildcard tail, keep the "/" of the rest path.
+		if strings.HasSuffix(name, wildcardSuffix) {
+			name = strings.TrimSuffix(name, wildcardSuffix)
+			value, ok := vars[name]
+			if !ok {
+				return "", fmt.Errorf("missing route variable %q for pattern %q", name, pattern)
+			}
+			segments := strings.Split(value, "/")
+			for j := range segments {
+				segments[j] = url.PathEscape(segments[j])
+			}
+			parts[i] = strings.Join(segments, "/")
+			continue
+		}
+
+		value, ok := vars[name]
+		if !ok {
+			return "", fmt.Errorf("missing route variable %q for pattern %q", name, pattern)
+		}
+
+		switch {
+		case isRegex:
+			if matched, err := regexp.MatchString(tomatch, value); err != nil || !matched {
+				return "", fmt.Errorf("route variable %q=%q doesn't match %q in pattern %q",
+					name, value, tomatch, pattern)
+			}
+		case tomatch != "":
+			if value != tomatch {
+				return "", fmt.Errorf("route variable %q=%q doesn't match %q in pattern %q",
+					name, value, tomatch, pattern)
+			}
+		case value == "":
+			return "", fmt.Errorf("empty route variable %q for pattern %q", name, pattern)
+		}
+
+		parts[i] = url.PathEscape(value)
+	}
+
+	return "/" + strings.Join(parts, "/"), nil
+}
+
+func buildPartialRoute(part string, vars map[string]string) (string, error) {
+	buf := bytes.NewBuffer(nil)
+	for _, piece := range splitPartialRoute(part) {
+		if piece.name == "" {
+			buf.WriteString(piece.text)
+			continue
+		}
+		value, ok := vars[piece.name]
+		if !ok {
+			return "", fmt.Errorf("missing route variable %q", piece.name)
+		}
+		if value == "" {
+			return "", fmt.Errorf("empty route variable %q", piece.name)
+		}
+		if piece.text != "" {
+			if matched, err := regexp.MatchString("^(?:"+piece.text+")$", value); err != nil || !matched {
+				return "", fmt.Errorf("route variable %q=%q doesn't match %q", piece.name, value, piece.text)
+			}
+		}
+		if piece.converter != nil {
+			if _, err := piece.converter.Convert(value); err != nil {
+				return "", fmt.Errorf("route variable %q=%q isn't a valid %s: %s", piece.name, value, piece.converter.converter, err)
+			}
+		}
+		buf.WriteString(url.PathEscape(value))
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,176 @@
+package mux
+
+import (
+	"context"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/ggicci/jungo/program"
+)
+
+// API versioning of `Mux`. Handlers registered with `WithVersion` on the same
+// pattern are chosen by the version a request asks for, which is taken from
+// (in order) the path prefix, the "Accept" header and the version header.
+// The highest compatible one wins, and the latest one serves the requests
+// without a version.
+type Versioning struct {
+	// Take the version from the first part of the path, e.g. "/v2.1/users".
+	// The part is stripped before matching the routes, i.e. "/users".
+	PathPrefix bool
+	// Take the version from the parameter of the "Accept" media type, e.g.
+	// "application/vnd.x+json;version=2.1" if AcceptParam is "version".
+	AcceptParam string
+	// Take the version from the header, e.g. "Api-Version".
+	Header string
+}
+
+// A range of API versions, both ends inclusive.
+type VersionRange struct {
+	Min program.VersionNo
+	// Nil if there's no upper bound.
+	Max *program.VersionNo
+}
+
+// Report whether the version is in the range. A nil range is the one of
+// the handlers without version, which contains any version. A nil version
+// is the one of the requests without version, which any range contains.
+func (vr *VersionRange) Contains(v *program.VersionNo) bool {
+	if vr == nil || v == nil {
+		return true
+	}
+	return v.Compare(vr.Min) >= 0 && (vr.Max == nil || v.Compare(*vr.Max) <= 0)
+}
+
+func (vr *VersionRange) overlaps(other *VersionRange) bool {
+	if vr == nil || other == nil {
+		return vr == nil && other == nil
+	}
+	return other.Contains(&vr.Min) || vr.Contains(&other.Min)
+}
+
+func (vr VersionRange) String() string {
+	if vr.Max == nil {
+		return ">=" + vr.Min.String()
+	}
+	if vr.Max.Compare(vr.Min) == 0 {
+		return vr.Min.String()
+	}
+	return vr.Min.String() + "-" + vr.Max.String()
+}
+
+type byVersionRange []VersionRange
+
+func (b byVersionRange) Len() int           { return len(b) }
+func (b byVersionRange) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byVersionRange) Less(i, j int) bool { return b[i].Min.Compare(b[j].Min) < 0 }
+
+// The API version a request asks for, stored in the request context by `Mux`.
+type apiVersion struct {
+	version program.VersionNo
+	// Where the version is taken from, "path", "accept" or "header".
+	source string
+}
+
+const apiVersionKey contextKey = 1
+
+// Get the API version the request asks for, see `Versioning`.
+func APIVersion(r *http.Request) (program.VersionNo, bool) {
+	if v, ok := r.Context().Value(apiVersionKey).(*apiVersion); ok {
+		return v.version, true
+	}
+	return program.VersionNo{}, false
+}
+
+func requestedAPIVersion(r *http.Request) *program.VersionNo {
+	if r == nil {
+		return nil
+	}
+	if v, ok := r.Context().Value(apiVersionKey).(*apiVersion); ok {
+		return &v.version
+	}
+	return nil
+}
+
+var versionPrefix = regexp.MustCompile(`^/[vV](\d+(\.\d+){0,2})(/|$)`)
+
+// Take the API version from the request, see `Versioning`.
+func (vs *Versioning) extract(r *http.Request) *apiVersion {
+	if vs.PathPrefix {
+		if m := versionPrefix.FindStringSubmatch(r.URL.Path); m != nil {
+			return &apiVersion{program.ParseVersionNumber(m[1]), "path"}
+		}
+	}
+	if vs.AcceptParam != "" {
+		for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+			_, params, err := mime.ParseMediaType(accept)
+			if v, ok := params[vs.AcceptParam]; err == nil && ok {
+				return &apiVersion{program.ParseVersionNumber(strings.TrimLeft(v, "vV")), "accept"}
+			}
+		}
+	}
+	if vs.Header != "" {
+		if v := r.Header.Get(vs.Header); v != "" {
+			return &apiVersion{program.ParseVersionNumber(strings.TrimLeft(v, "vV")), "header"}
+		}
+	}
+	return nil
+}
+
+// Strip the version prefix from the path, e.g. "/v2.1/users" to "/users".
+func (vs *Versioning) trimPath(p string) string {
+	if !vs.PathPrefix {
+		return p
+	}
+	if m := versionPrefix.FindStringSubmatchIndex(p); m != nil {
+		return "/" + p[m[1]:]
+	}
+	return p
+}
+
+// Store the API version the request asks for in the request context.
+func (m *Mux) withAPIVersion(r *http.Request) *http.Request {
+	if m.Versioning == nil {
+		return r
+	}
+	if _, ok := r.Context().Value(apiVersionKey).(*apiVersion); ok {
+		return r
+	}
+	v := m.Versioning.extract(r)
+	if v == nil {
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), apiVersionKey, v))
+}
+
+// Respond the versions supported if none of them is the one asked for, with
+// "406 Not Acceptable" if it's from the "Accept" header, or "400 Bad Request".
+func unsupportedVersionHandler(supported []VersionRange) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		code, version := http.StatusBadRequest, ""
+		if v, ok := r.Context().Value(apiVersionKey).(*apiVersion); ok {
+			version = v.version.String()
+			if v.source == "accept" {
+				code = http.StatusNotAcceptable
+			}
+		}
+		body := struct {
+			Error             string   `json:"error"`
+			Version           string   `json:"version"`
+			SupportedVersions []string `json:"supported_versions"`
+		}{
+			Error:   "unsupported API version",
+			Version: version,
+		}
+		for _, vr := range supported {
+			body.SupportedVersions = append(body.SupportedVersions, vr.String())
+		}
+		rw.Header().Set("Content-Type", "application/json")
+		rw.WriteHeader(code)
+		enc := json.NewEncoder(rw)
+		enc.SetEscapeHTML(false)
+		enc.Encode(body)
+	})
+}
@@ -0,0 +1,224 @@
+package mux
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// A registered pattern, see `Router.Walk`.
+type RouteInfo struct {
+	Pattern string `json:"pattern"`
+	// The host pattern, see `WithHost`. Only set by `Mux.Walk`.
+	Host string `json:"host,omitempty"`
+	// Sorted methods bound to the pattern, "*" for any method.
+	Methods []string `json:"methods"`
+	// Names of the route variables in order.
+	Vars     []string      `json:"vars,omitempty"`
+	Parts    []RoutePart   `json:"parts"`
+	Handlers []HandlerInfo `json:"handlers"`
+}
+
+// A part of the pattern between "/".
+type RoutePart struct {
+	// The part as written in the pattern, e.g. "{id}\d+".
+	Part string `json:"part"`
+	// "static", "partial", "regex", "any" or "wildcard", in the order of
+	// priority, the higher one is tried first.
+	Kind     string `json:"kind"`
+	Priority int    `json:"priority"`
+	// The regex matching the part, if any.
+	Regex    string   `json:"regex,omitempty"`
+	Vars     []string `json:"vars,omitempty"`
+	Optional bool     `json:"optional,omitempty"`
+	// The converters of the route variables by name, e.g. "int" of "{id:int}".
+	Converters map[string]string `json:"converters,omitempty"`
+}
+
+// A handler bound to the pattern, with the options given at registration.
+type HandlerInfo struct {
+	// "*" for any method.
+	Method  string      `json:"method"`
+	Handler interface{} `json:"-"`
+	Name    string      `json:"name,omitempty"`
+	// The API versions served, see `WithVersion`.
+	Version  string         `json:"version,omitempty"`
+	Matchers int            `json:"matchers,omitempty"`
+	Defaults RouteVariables `json:"defaults,omitempty"`
+	// See `WithMetadata`.
+	Metadata Metadata `json:"metadata,omitempty"`
+}
+
+var routeKinds = map[int]string{
+	kAbsolutePattern: "static",
+	kPartialPattern:  "partial",
+	kRegexPattern:    "regex",
+	kAnyPattern:      "any",
+	kWildcardPattern: "wildcard",
+}
+
+func (ep *endpoint) info() RouteInfo {
+	info := RouteInfo{Pattern: ep.pattern, Parts: make([]RoutePart, 0, len(ep.routes))}
+	for _, method := range ep.methods() {
+		if method == "" {
+			method = "*"
+		}
+		info.Methods = append(info.Methods, method)
+	}
+	for _, r := range ep.routes {
+		part := RoutePart{
+			Part:     r.part,
+			Kind:     routeKinds[r.priority],
+			Priority: r.priority,
+			Vars:     r.names(),
+			Optional: r.optional,
+		}
+		if r.regex != nil {
+			part.Regex = r.regex.String()
+		}
+		for _, rc := range r.converters {
+			if part.Converters == nil {
+				part.Converters = make(map[string]string)
+			}
+			part.Converters[rc.name] = rc.converter
+		}
+		info.Vars = append(info.Vars, part.Vars...)
+		info.Parts = append(info.Parts, part)
+	}
+	for i, method := range ep.methods() {
+		for _, b := range ep.handlers[method] {
+			h := HandlerInfo{
+				Method:   info.Methods[i],
+				Handler:  b.handler,
+				Name:     b.options.name,
+				Matchers: len(b.options.matchers),
+				Defaults: b.options.defaults,
+				Metadata: b.options.metadata,
+			}
+			if b.options.versions != nil {
+				h.Version = b.options.versions.String()
+			}
+			info.Handlers = append(info.Handlers, h)
+		}
+	}
+	return info
+}
+
+// Call fn with each registered pattern in the order registered, stop at the
+// first error returned, which is returned by `Walk`. The routes are the ones
+// of a snapshot, it's safe to change them in fn.
+func (rt *Router) Walk(fn func(RouteInfo) error) error {
+	for _, ep := range rt.load().endpoints {
+		if err := fn(ep.info()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// List the registered patterns in the order registered, see `Walk`.
+func (rt *Router) Routes() []RouteInfo {
+	routes := make([]RouteInfo, 0)
+	rt.Walk(func(info RouteInfo) error {
+		routes = append(routes, info)
+		return nil
+	})
+	return routes
+}
+
+// Walk the host-agnostic routes first, then the ones of each host, see
+// `Router.Walk`.
+func (m *Mux) Walk(fn func(RouteInfo) error) error {
+	if err := m.router.Walk(fn); err != nil {
+		return err
+	}
+	for _, hr := range m.loadHosts() {
+		err := hr.router.Walk(func(info RouteInfo) error {
+			info.Host = hr.pattern
+			return fn(info)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// List the routes, see `Walk`.
+func (m *Mux) Routes() []RouteInfo {
+	routes := make([]RouteInfo, 0)
+	m.Walk(func(info RouteInfo) error {
+		routes = append(routes, info)
+		return nil
+	})
+	return routes
+}
+
+// Write the routes as an indented JSON array, e.g. to check the route table
+// into review.
+func ExportJSON(w io.Writer, routes []RouteInfo) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(routes)
+}
+
+// Write the routes as a Graphviz DOT digraph, one node per part, e.g.
+// ExportDOT(f, mux.Routes()) then "dot -Tsvg routes.dot > routes.svg".
+// The nodes a pattern ends at are boxes labeled with the methods.
+func ExportDOT(w io.Writer, routes []RouteInfo) error {
+	type dotNode struct {
+		id, label string
+		methods   []string
+	}
+	ids := make(map[string]*dotNode)
+	nodes := make([]*dotNode, 0)
+	edges := make([][2]string, 0)
+	addNode := func(key, parent, label string) *dotNode {
+		if n, ok := ids[key]; ok {
+			return n
+		}
+		n := &dotNode{id: fmt.Sprintf("n%d", len(nodes)), label: label}
+		ids[key] = n
+		nodes = append(nodes, n)
+		if parent != "" {
+			edges = append(edges, [2]string{ids[parent].id, n.id})
+		}
+		return n
+	}
+
+	for _, info := range routes {
+		key := info.Host + "/"
+		n := addNode(key, "", info.Host+"/")
+		for _, part := range info.Parts {
+			parent, label := key, part.Part
+			if label == "" {
+				label = "/" // the trailing "/"
+			}
+			key += part.Part + "/"
+			n = addNode(key, parent, label)
+		}
+		n.methods = append(n.methods, info.Methods...)
+	}
+
+	buf := new(strings.Builder)
+	buf.WriteString("digraph routes {\n\trankdir=LR;\n\tnode [shape=ellipse];\n")
+	for _, n := range nodes {
+		if len(n.methods) > 0 {
+			fmt.Fprintf(buf, "\t%s [label=%s, shape=box];\n", n.id, dotQuote(n.label+"\n"+strings.Join(n.methods, ",")))
+		} else {
+			fmt.Fprintf(buf, "\t%s [label=%s];\n", n.id, dotQuote(n.label))
+		}
+	}
+	for _, e := range edges {
+		fmt.Fprintf(buf, "\t%s -> %s;\n", e[0], e[1])
+	}
+	buf.WriteString("}\n")
+	_, err := io.WriteString(w, buf.String())
+	return err
+}
+
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func dotQuote(s string) string { return `"` + dotEscaper.Replace(s) + `"` }
@@ -80,6 +80,20 @@ func (rdr *Renderer) RegisterTemplateFunc(name string, fn interface{}) {
 	rdr.templateFuncs[name] = fn
 }
 
+// Register the reverse URL builder, e.g. `mux.Mux.URL`, as template function "url".
+// Route variables in templates can be of any type, they are formatted by fmt.Sprint.
+// e.g. <a href="{{url "user.show" "id" .User.ID}}">{{.User.Name}}</a>
+// NB: Do this before parsing the template files.
+func (rdr *Renderer) RegisterURLFunc(fn func(name string, pairs ...string) (string, error)) {
+	rdr.RegisterTemplateFunc("url", func(name string, pairs ...interface{}) (string, error) {
+		strs := make([]string, len(pairs))
+		for i, x := range pairs {
+			strs[i] = fmt.Sprint(x)
+		}
+		return fn(name, strs...)
+	})
+}
+
 // Start parsing the files under the specified template dir.
 // Returns parse log and error.
 func (rdr *Renderer) ParseFiles() error {
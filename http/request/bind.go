@@ -0,0 +1,348 @@
+package request
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/textproto"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ggicci/jungo/http/mux"
+)
+
+// The sources of the fields, in the order tried, see `Bind`.
+var bindSources = []string{"route", "query", "form", "header"}
+
+// An error of a field failing to bind, e.g. a query parameter which isn't a
+// number. The fields are named as in the request, e.g. "page" of
+// `query:"page"`.
+type FieldError struct {
+	// "route", "query", "form", "header" or "json".
+	Source string `json:"source"`
+	Field  string `json:"field"`
+	Value  string `json:"value,omitempty"`
+	// Why, e.g. "invalid int".
+	Message string `json:"message"`
+}
+
+func (fe *FieldError) Error() string {
+	if fe.Field == "" {
+		return fmt.Sprintf("%s: %s", fe.Source, fe.Message)
+	}
+	return fmt.Sprintf("%s %q: %s", fe.Source, fe.Field, fe.Message)
+}
+
+// The errors of the fields, returned by `Bind` as a whole. It's a client
+// error, i.e. "400 Bad Request".
+type FieldErrors []*FieldError
+
+func (fes FieldErrors) Error() string {
+	msgs := make([]string, 0, len(fes))
+	for _, fe := range fes {
+		msgs = append(msgs, fe.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Fill the struct v points to from the request, by the tags of the fields:
+//
+//	type ListPosts struct {
+//		User   int       `route:"id"`
+//		Page   int       `query:"page" default:"1"`
+//		Tags   []string  `query:"tag"`
+//		Since  *time.Time `query:"since" layout:"2006-01-02"`
+//		Title  string    `form:"title"`
+//		Tenant string    `header:"X-Tenant"`
+//		Body   Post      `json:"body"`
+//	}
+//
+// The route variables are the ones matched by `mux.Mux`. The form is the body
+// one, i.e. `http.Request.PostForm`. A JSON body is decoded into the fields of
+// a json tag first, then the other sources overwrite the fields they have. The
+// fields without a json tag, e.g. `header:"X-Tenant"`, are never set from it.
+//
+// A slice takes all the values, the others take the first one. A pointer is
+// allocated if the value is present. Besides the basic kinds, time.Duration,
+// time.Time (RFC 3339, or the layout tag) and `encoding.TextUnmarshaler` are
+// supported. The default tag is the value of an absent field which is still
+// zero, e.g. not decoded from the JSON body, split by "," of a slice. Embedded
+// structs are filled as part of v.
+//
+// Returns `FieldErrors` if any field fails, the other fields are still filled.
+func Bind(r *http.Request, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("bind: non-nil pointer to struct expected, got %T", v)
+	}
+
+	var fes FieldErrors
+	if fe := bindJSON(r, rv.Elem()); fe != nil {
+		fes = append(fes, fe)
+	}
+
+	var form map[string][]string
+	if hasTag(rv.Elem().Type(), "form") {
+		if err := parseForm(r); err != nil {
+			fes = append(fes, &FieldError{Source: "form", Message: err.Error()})
+		}
+		form = r.PostForm
+	}
+	values := map[string]func(name string) []string{
+		"route": func(name string) []string {
+			if value, ok := mux.RouteVars(r)[name]; ok {
+				return []string{value}
+			}
+			return nil
+		},
+		"query":  func(name string) []string { return r.URL.Query()[name] },
+		"form":   func(name string) []string { return form[name] },
+		"header": func(name string) []string { return r.Header[textproto.CanonicalMIMEHeaderKey(name)] },
+	}
+
+	bindFields(rv.Elem(), func(field reflect.StructField, fv reflect.Value) {
+		var source, name string
+		var vs []string
+		for _, src := range bindSources {
+			if n := field.Tag.Get(src); n != "" && n != "-" {
+				if source == "" {
+					source, name = src, n
+				}
+				if vs = values[src](n); len(vs) > 0 {
+					source, name = src, n
+					break
+				}
+			}
+		}
+		if source == "" {
+			return
+		}
+		if len(vs) == 0 {
+			// Keep the value decoded from the JSON body, if any.
+			if !fv.IsZero() {
+				return
+			}
+			if vs = defaultValues(field, fv); len(vs) == 0 {
+				return
+			}
+		}
+		if err := setValue(fv, vs, field.Tag.Get("layout")); err != nil {
+			fes = append(fes, &FieldError{Source: source, Field: name, Value: vs[0], Message: err.Error()})
+		}
+	})
+
+	if len(fes) > 0 {
+		return fes
+	}
+	return nil
+}
+
+// Decode the JSON body into the fields of a json tag of v, if any, through a
+// struct of only them, see `jsonFields`.
+func bindJSON(r *http.Request, v reflect.Value) *FieldError {
+	if r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0 {
+		return nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json") {
+		return nil
+	}
+
+	fields, values := jsonFields(v)
+	if len(fields) == 0 {
+		return nil
+	}
+	shadow := reflect.New(reflect.StructOf(fields)).Elem()
+	for i, fv := range values {
+		shadow.Field(i).Set(fv)
+	}
+	err := json.NewDecoder(r.Body).Decode(shadow.Addr().Interface())
+	for i, fv := range values {
+		fv.Set(shadow.Field(i))
+	}
+	if err == nil || errors.Is(err, io.EOF) {
+		return nil
+	}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		expected := typeErr.Type.String()
+		if typeErr.Type.Name() == "" {
+			expected = typeErr.Type.Kind().String()
+		}
+		return &FieldError{Source: "json", Field: typeErr.Field, Value: typeErr.Value,
+			Message: "invalid " + expected}
+	}
+	return &FieldError{Source: "json", Message: err.Error()}
+}
+
+// Get the exported fields of a json tag of the struct, the ones of the embedded
+// structs without a json tag included, as the fields of a struct to decode the
+// JSON body into, together with the values they're copied to and from.
+func jsonFields(v reflect.Value) (fields []reflect.StructField, values []reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, ok := field.Tag.Lookup("json")
+		if !ok && field.Anonymous && field.Type.Kind() == reflect.Struct {
+			fs, vs := jsonFields(v.Field(i))
+			fields, values = append(fields, fs...), append(values, vs...)
+			continue
+		}
+		if !ok || tag == "-" || field.PkgPath != "" {
+			continue
+		}
+		// The key defaults to the field name, which isn't kept.
+		if strings.HasPrefix(tag, ",") {
+			tag = field.Name + tag
+		} else if tag == "" {
+			tag = field.Name
+		}
+		fields = append(fields, reflect.StructField{
+			Name: fmt.Sprintf("F%d", len(fields)),
+			Type: field.Type,
+			Tag:  reflect.StructTag(fmt.Sprintf("json:%q", tag)),
+		})
+		values = append(values, v.Field(i))
+	}
+	return
+}
+
+func parseForm(r *http.Request) error {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		return r.ParseMultipartForm(32 << 20)
+	}
+	return r.ParseForm()
+}
+
+// Call fn with each exported field of the struct, the ones of the embedded
+// structs included.
+func bindFields(v reflect.Value, fn func(reflect.StructField, reflect.Value)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			bindFields(v.Field(i), fn)
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		fn(field, v.Field(i))
+	}
+}
+
+// Report whether any field of the struct has the tag.
+func hasTag(t reflect.Type, key string) bool {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if _, ok := field.Tag.Lookup(key); ok {
+			return true
+		}
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && hasTag(field.Type, key) {
+			return true
+		}
+	}
+	return false
+}
+
+func defaultValues(field reflect.StructField, fv reflect.Value) []string {
+	def, ok := field.Tag.Lookup("default")
+	if !ok {
+		return nil
+	}
+	if fv.Kind() == reflect.Slice {
+		return strings.Split(def, ",")
+	}
+	return []string{def}
+}
+
+var (
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	timeType            = reflect.TypeOf(time.Time{})
+	durationType        = reflect.TypeOf(time.Duration(0))
+)
+
+// Set the value from the strings, all of them for a slice, or the first one.
+func setValue(v reflect.Value, values []string, layout string) error {
+	if v.Kind() == reflect.Ptr {
+		elem := reflect.New(v.Type().Elem())
+		if err := setValue(elem.Elem(), values, layout); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	}
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8 {
+		slice := reflect.MakeSlice(v.Type(), len(values), len(values))
+		for i, s := range values {
+			if err := setValue(slice.Index(i), []string{s}, layout); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+		return nil
+	}
+
+	s := values[0]
+	switch {
+	case v.Type() == timeType && layout != "":
+		t, err := time.Parse(layout, s)
+		if err != nil {
+			return fmt.Errorf("invalid time of layout %q", layout)
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	case v.Type() == durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("invalid duration")
+		}
+		v.SetInt(int64(d))
+		return nil
+	case reflect.PtrTo(v.Type()).Implements(textUnmarshalerType):
+		if err := v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)); err != nil {
+			return fmt.Errorf("invalid %s: %s", v.Type(), err)
+		}
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("invalid bool")
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid %s", v.Kind())
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid %s", v.Kind())
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid %s", v.Kind())
+		}
+		v.SetFloat(f)
+	case reflect.Slice: // []byte
+		v.SetBytes([]byte(s))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package request
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ggicci/jungo/http/mux"
+)
+
+type Paging struct {
+	Page int `query:"page" default:"1"`
+	Size int `query:"size" default:"20"`
+}
+
+type ListPosts struct {
+	Paging
+	User    int           `route:"id"`
+	Tags    []string      `query:"tag"`
+	Since   *time.Time    `query:"since" layout:"2006-01-02"`
+	Until   time.Time     `query:"until"`
+	Timeout time.Duration `query:"timeout"`
+	IP      net.IP        `header:"X-Real-IP"`
+	Tenant  string        `header:"X-Tenant"`
+	Ratio   *float64      `query:"ratio"`
+	Sort    []string      `query:"sort" default:"date,title"`
+	Title   string        `form:"title"`
+	Draft   bool          `form:"draft"`
+	Body    struct {
+		Text string `json:"text"`
+	} `json:"body"`
+}
+
+func bindRequest(r *http.Request, pattern string, v interface{}) (err error) {
+	m := mux.NewMux()
+	m.HandleFunc(pattern, func(rw http.ResponseWriter, r *http.Request) { err = Bind(r, v) })
+	m.ServeHTTP(httptest.NewRecorder(), r)
+	return
+}
+
+func TestBind(t *testing.T) {
+	r := httptest.NewRequest("GET", "/users/7/posts?tag=go&tag=web&since=2024-03-01&until=2024-03-02T10:00:00Z&timeout=1m30s", nil)
+	r.Header.Set("X-Real-IP", "10.0.0.1")
+	r.Header.Set("X-Tenant", "acme")
+
+	var v ListPosts
+	if err := bindRequest(r, "/users/{id}/posts", &v); err != nil {
+		t.Fatal(err)
+	}
+	got := fmt.Sprintf("%d %d %d %v %s %s %s %s %s %v %v", v.User, v.Page, v.Size, v.Tags,
+		v.Since.Format("Jan 2"), v.Until.Format(time.Kitchen), v.Timeout, v.IP, v.Tenant, v.Ratio, v.Sort)
+	expected := "7 1 20 [go web] Mar 1 10:00AM 1m30s 10.0.0.1 acme <nil> [date title]"
+	if got != expected {
+		t.Errorf("expects %s, but got %s", expected, got)
+	}
+}
+
+func TestBindFormAndJSON(t *testing.T) {
+	r := httptest.NewRequest("POST", "/posts?page=2", strings.NewReader("title=Hello&draft=true"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	var v ListPosts
+	if err := bindRequest(r, "/posts", &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Title != "Hello" || !v.Draft || v.Page != 2 {
+		t.Errorf("unexpected %+v", v)
+	}
+
+	r = httptest.NewRequest("POST", "/posts?page=3", strings.NewReader(`{"body": {"text": "hi"}, "Page": 9, "Tenant": "other"}`))
+	r.Header.Set("Content-Type", "application/json; charset=utf-8")
+	v = ListPosts{}
+	if err := bindRequest(r, "/posts", &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Body.Text != "hi" || v.Page != 3 {
+		t.Errorf("expects the query to overwrite the JSON, but got %+v", v)
+	}
+	if v.Tenant != "" {
+		t.Errorf("expects the header field not to be set from the JSON, but got %q", v.Tenant)
+	}
+
+	type Search struct {
+		Page int `json:"page" query:"page" default:"1"`
+		Size int `json:"size" query:"size" default:"20"`
+	}
+	r = httptest.NewRequest("POST", "/search", strings.NewReader(`{"page": 5}`))
+	r.Header.Set("Content-Type", "application/json")
+	var s Search
+	if err := bindRequest(r, "/search", &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Page != 5 || s.Size != 20 {
+		t.Errorf("expects the defaults not to overwrite the JSON, but got %+v", s)
+	}
+}
+
+func TestBindErrors(t *testing.T) {
+	r := httptest.NewRequest("POST", "/users/x/posts?page=a&ratio=b&since=03-01", strings.NewReader(`{"body": 1}`))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("X-Real-IP", "1.2.3")
+
+	var v ListPosts
+	err := bindRequest(r, "/users/{id}/posts", &v)
+	var fes FieldErrors
+	if !errors.As(err, &fes) {
+		t.Fatalf("expects FieldErrors, but got %v", err)
+	}
+	fields := make([]string, 0)
+	for _, fe := range fes {
+		fields = append(fields, fe.Source+" "+fe.Field)
+	}
+	expected := "json body,query page,route id,query since,header X-Real-IP,query ratio"
+	if got := strings.Join(fields, ","); got != expected {
+		t.Errorf("expects errors of %s, but got %s (%v)", expected, got, err)
+	}
+	if v.Size != 20 {
+		t.Errorf("expects the other fields to be filled, but got %+v", v)
+	}
+
+	if err := Bind(r, v); err == nil || errors.As(err, &fes) {
+		t.Errorf("expects an error of a non-pointer, but got %v", err)
+	}
+}
@@ -0,0 +1,424 @@
+package request
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// An error of a field failing a rule, see `Validate`.
+type ValidationError struct {
+	// The path of the field, named as in the request, e.g. "items[2].name".
+	Field  string   `json:"field"`
+	Rule   string   `json:"rule"`
+	Params []string `json:"params,omitempty"`
+	// Why, e.g. "must be at least 1".
+	Message string `json:"message"`
+}
+
+func (ve *ValidationError) Error() string {
+	return fmt.Sprintf("%s %s", ve.Field, ve.Message)
+}
+
+// The errors of the fields, returned by `Validate` as a whole.
+type ValidationErrors []*ValidationError
+
+func (ves ValidationErrors) Error() string {
+	msgs := make([]string, 0, len(ves))
+	for _, ve := range ves {
+		msgs = append(msgs, ve.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// The first error of the field, nil if none. e.g. in a template:
+// {{with .Errors.Field "email"}}<span class="error">{{.Message}}</span>{{end}}
+func (ves ValidationErrors) Field(path string) *ValidationError {
+	for _, ve := range ves {
+		if ve.Field == path {
+			return ve
+		}
+	}
+	return nil
+}
+
+// The field a rule checks, see `RegisterRule`.
+type Field struct {
+	// The value of the field, the pointers dereferenced.
+	Value reflect.Value
+	// The struct the field is in, for the rules across fields.
+	Struct reflect.Value
+	Params []string
+}
+
+// Check the field, returns false if it's invalid.
+type Rule func(f Field) bool
+
+type rule struct {
+	check   Rule
+	message func(f Field) string
+	// Check the params of the rule, nil if any.
+	params func(f Field) error
+}
+
+var (
+	rulesMutex sync.RWMutex
+	rules      = map[string]*rule{
+		"required": {
+			check:   func(f Field) bool { return f.Value.IsValid() && !f.Value.IsZero() },
+			message: staticMessage("is required"),
+		},
+		"min": {
+			check:   func(f Field) bool { return compareParam(f) >= 0 },
+			message: sizeMessage("must be at least %s", "must have at least %s items", "must be at least %s characters long"),
+			params:  numberParam,
+		},
+		"max": {
+			check:   func(f Field) bool { return compareParam(f) <= 0 },
+			message: sizeMessage("must be at most %s", "must have at most %s items", "must be at most %s characters long"),
+			params:  numberParam,
+		},
+		"len": {
+			check:   func(f Field) bool { return compareParam(f) == 0 },
+			message: sizeMessage("must be %s", "must have %s items", "must be %s characters long"),
+			params:  numberParam,
+		},
+		"email": {
+			check: func(f Field) bool {
+				addr, err := mail.ParseAddress(f.Value.String())
+				return f.Value.Kind() == reflect.String && err == nil && addr.Address == f.Value.String()
+			},
+			message: staticMessage("must be a valid email address"),
+		},
+		"oneof": {
+			check: func(f Field) bool {
+				s := fmt.Sprint(f.Value.Interface())
+				for _, p := range f.Params {
+					if s == p {
+						return true
+					}
+				}
+				return false
+			},
+			message: paramsMessage("must be one of %s"),
+			params: func(f Field) error {
+				if len(f.Params) == 0 {
+					return fmt.Errorf("values expected")
+				}
+				return nil
+			},
+		},
+		"eqfield": {
+			check:   func(f Field) bool { return compareField(f) == 0 },
+			message: paramsMessage("must be equal to %s"),
+			params:  fieldParam,
+		},
+		"nefield": {
+			check:   func(f Field) bool { return compareField(f) != 0 },
+			message: paramsMessage("must not be equal to %s"),
+			params:  fieldParam,
+		},
+		"gtfield": {
+			check:   func(f Field) bool { return compareField(f) == 1 },
+			message: paramsMessage("must be greater than %s"),
+			params:  fieldParam,
+		},
+		"ltfield": {
+			check:   func(f Field) bool { return compareField(f) == -1 },
+			message: paramsMessage("must be less than %s"),
+			params:  fieldParam,
+		},
+	}
+)
+
+// Register the rule by name, so fields can be tagged by it, e.g. rule "prefix"
+// checks `validate:"prefix=img_"`. The message is formatted with the params
+// joined by " ", e.g. "must start with %s". Panics if the name is taken.
+func RegisterRule(name, message string, check Rule) {
+	if name == "" || strings.ContainsAny(name, ",= ") || name == "omitempty" {
+		panic(fmt.Errorf("invalid rule name %q", name))
+	}
+	if check == nil {
+		panic(fmt.Errorf("nil rule %q", name))
+	}
+
+	rulesMutex.Lock()
+	defer rulesMutex.Unlock()
+	if _, ok := rules[name]; ok {
+		panic(fmt.Errorf("rule %q already registered", name))
+	}
+	rules[name] = &rule{check: check, message: paramsMessage(message)}
+}
+
+// Returns nil if not found.
+func lookupRule(name string) *rule {
+	rulesMutex.RLock()
+	defer rulesMutex.RUnlock()
+	return rules[name]
+}
+
+// Check the fields of the struct v (or points to) by their validate tags, the
+// rules separated by ",", the params of a rule following "=" separated by " ":
+//
+//	type SignUp struct {
+//		Name     string `form:"name" validate:"required,max=32"`
+//		Email    string `form:"email" validate:"required,email"`
+//		Plan     string `form:"plan" validate:"oneof=free pro"`
+//		Password string `form:"password" validate:"min=8"`
+//		Confirm  string `form:"confirm" validate:"eqfield=Password"`
+//		Age      *int   `form:"age" validate:"omitempty,min=18"`
+//	}
+//
+// The built-in rules are required, min, max, len (of numbers, or the length of
+// strings, slices and maps), email, oneof, and eqfield, nefield, gtfield and
+// ltfield comparing with the other field of the struct by its Go name. A nil
+// pointer only fails "required", "omitempty" skips the rest if the field is
+// zero. See `RegisterRule` for the custom ones. The nested structs, and the
+// ones in slices are checked too.
+//
+// Returns `ValidationErrors` if any field fails, or an error of a bad tag, i.e.
+// an unknown rule or invalid params like "max=abc", of any field.
+func Validate(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("validate: struct expected, got %T", v)
+	}
+
+	var ves ValidationErrors
+	if err := validateStruct(rv, "", &ves); err != nil {
+		return err
+	}
+	if len(ves) > 0 {
+		return ves
+	}
+	return nil
+}
+
+func validateStruct(v reflect.Value, prefix string, ves *ValidationErrors) error {
+	var err error
+	bindFields(v, func(field reflect.StructField, fv reflect.Value) {
+		if err != nil {
+			return
+		}
+		path := prefix + fieldName(field)
+		if tag := field.Tag.Get("validate"); tag != "" && tag != "-" {
+			if err = validateField(fv, v, path, tag, ves); err != nil {
+				return
+			}
+		}
+		err = validateNested(fv, path, ves)
+	})
+	return err
+}
+
+// Check the structs in the field, if any.
+func validateNested(v reflect.Value, path string, ves *ValidationErrors) error {
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	switch {
+	case v.Kind() == reflect.Struct && v.Type() != timeType:
+		return validateStruct(v, path+".", ves)
+	case v.Kind() == reflect.Slice || v.Kind() == reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := validateNested(v.Index(i), fmt.Sprintf("%s[%d]", path, i), ves); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Check the field by the rules of the tag. The rules and their params are
+// checked first, so a bad tag is an error even if the field is omitted.
+func validateField(v, parent reflect.Value, path, tag string, ves *ValidationErrors) error {
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	isNil := v.Kind() == reflect.Ptr
+
+	type item struct {
+		name string
+		rule *rule
+		f    Field
+	}
+	items := make([]item, 0)
+	for _, x := range strings.Split(tag, ",") {
+		name, params := strings.TrimSpace(x), []string(nil)
+		if i := strings.Index(name, "="); i >= 0 {
+			name, params = name[:i], strings.Fields(name[i+1:])
+		}
+		f := Field{Value: v, Struct: parent, Params: params}
+		if name == "omitempty" {
+			items = append(items, item{name: name})
+			continue
+		}
+		r := lookupRule(name)
+		if r == nil {
+			return fmt.Errorf("validate: unknown rule %q of %s", name, path)
+		}
+		if r.params != nil {
+			if err := r.params(f); err != nil {
+				return fmt.Errorf("validate: invalid params of rule %q of %s: %s", name, path, err)
+			}
+		}
+		items = append(items, item{name, r, f})
+	}
+
+	for _, x := range items {
+		if x.name == "omitempty" {
+			if isNil || v.IsZero() {
+				return nil
+			}
+			continue
+		}
+		if isNil {
+			if x.name != "required" {
+				continue
+			}
+			x.f.Value = reflect.Value{}
+		}
+		if !x.rule.check(x.f) {
+			*ves = append(*ves, &ValidationError{Field: path, Rule: x.name, Params: x.f.Params, Message: x.rule.message(x.f)})
+		}
+	}
+	return nil
+}
+
+// The name of the field in the request, i.e. the one of its json, form,
+// query, route or header tag, or its Go name.
+func fieldName(field reflect.StructField) string {
+	for _, key := range []string{"json", "form", "query", "route", "header"} {
+		if name := strings.Split(field.Tag.Get(key), ",")[0]; name != "" && name != "-" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+// The param of the rules on the value or the length, a number.
+func numberParam(f Field) error {
+	if len(f.Params) != 1 {
+		return fmt.Errorf("a number expected")
+	}
+	if _, err := strconv.ParseFloat(f.Params[0], 64); err != nil {
+		return fmt.Errorf("a number expected, got %q", f.Params[0])
+	}
+	return nil
+}
+
+// The param of the rules across fields, the Go name of the other field.
+func fieldParam(f Field) error {
+	if len(f.Params) != 1 {
+		return fmt.Errorf("a field name expected")
+	}
+	if !f.Struct.FieldByName(f.Params[0]).IsValid() {
+		return fmt.Errorf("field %q not found", f.Params[0])
+	}
+	return nil
+}
+
+// Compare the value, or the length of it, with the first param, a number, see
+// `numberParam`. Returns -2 if they aren't comparable.
+func compareParam(f Field) int {
+	if len(f.Params) == 0 {
+		return -2
+	}
+	p, err := strconv.ParseFloat(f.Params[0], 64)
+	if err != nil {
+		return -2
+	}
+	var x float64
+	switch f.Value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		x = float64(f.Value.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		x = float64(f.Value.Uint())
+	case reflect.Float32, reflect.Float64:
+		x = f.Value.Float()
+	case reflect.String:
+		x = float64(utf8.RuneCountInString(f.Value.String()))
+	case reflect.Slice, reflect.Map, reflect.Array:
+		x = float64(f.Value.Len())
+	default:
+		return -2
+	}
+	return compareFloat(x, p)
+}
+
+// Compare the value with the other field named by the first param. Returns -2
+// if they aren't comparable.
+func compareField(f Field) int {
+	if len(f.Params) == 0 || !f.Struct.IsValid() {
+		return -2
+	}
+	other := f.Struct.FieldByName(f.Params[0])
+	for other.Kind() == reflect.Ptr && !other.IsNil() {
+		other = other.Elem()
+	}
+	if !other.IsValid() || other.Type() != f.Value.Type() {
+		return -2
+	}
+	switch f.Value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return compareFloat(float64(f.Value.Int()), float64(other.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return compareFloat(float64(f.Value.Uint()), float64(other.Uint()))
+	case reflect.Float32, reflect.Float64:
+		return compareFloat(f.Value.Float(), other.Float())
+	case reflect.String:
+		return strings.Compare(f.Value.String(), other.String())
+	}
+	if t, ok := f.Value.Interface().(time.Time); ok {
+		return t.Compare(other.Interface().(time.Time))
+	}
+	if reflect.DeepEqual(f.Value.Interface(), other.Interface()) {
+		return 0
+	}
+	return -2
+}
+
+func compareFloat(x, y float64) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+func staticMessage(msg string) func(Field) string {
+	return func(Field) string { return msg }
+}
+
+func paramsMessage(format string) func(Field) string {
+	return func(f Field) string {
+		if !strings.Contains(format, "%s") {
+			return format
+		}
+		return fmt.Sprintf(format, strings.Join(f.Params, " "))
+	}
+}
+
+// The message of the rules on the value or the length, by the kind of the field.
+func sizeMessage(number, items, chars string) func(Field) string {
+	return func(f Field) string {
+		format := number
+		switch f.Value.Kind() {
+		case reflect.String:
+			format = chars
+		case reflect.Slice, reflect.Map, reflect.Array:
+			format = items
+		}
+		return fmt.Sprintf(format, strings.Join(f.Params, " "))
+	}
+}
@@ -0,0 +1,165 @@
+package request
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type SignUp struct {
+	Name     string    `form:"name" validate:"required,max=8"`
+	Email    string    `form:"email" validate:"required,email"`
+	Plan     string    `form:"plan" validate:"oneof=free pro"`
+	Password string    `form:"password" validate:"min=8"`
+	Confirm  string    `form:"confirm" validate:"eqfield=Password"`
+	Age      *int      `form:"age" validate:"omitempty,min=18"`
+	Tags     []string  `json:"tags" validate:"max=2"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end" validate:"gtfield=Start"`
+	Address  *struct {
+		City string `json:"city" validate:"required"`
+	} `json:"address"`
+	Items []struct {
+		Qty int `json:"qty" validate:"min=1,max=100"`
+	} `json:"items"`
+}
+
+func validSignUp() *SignUp {
+	now := time.Now()
+	return &SignUp{
+		Name: "ggicci", Email: "ggicci@example.com", Plan: "pro",
+		Password: "12345678", Confirm: "12345678",
+		Start: now, End: now.Add(time.Hour),
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := Validate(validSignUp()); err != nil {
+		t.Fatalf("expects no error, but got %v", err)
+	}
+
+	age := 16
+	v := validSignUp()
+	v.Name = "ggicci-ggicci"
+	v.Email = "Ggicci <ggicci@example.com>"
+	v.Plan = "gold"
+	v.Confirm = "1234567"
+	v.Age = &age
+	v.Tags = []string{"a", "b", "c"}
+	v.End = v.Start
+	v.Address = &struct {
+		City string `json:"city" validate:"required"`
+	}{}
+	v.Items = []struct {
+		Qty int `json:"qty" validate:"min=1,max=100"`
+	}{{Qty: 1}, {Qty: 0}}
+
+	err := Validate(v)
+	var ves ValidationErrors
+	if !errors.As(err, &ves) {
+		t.Fatalf("expects ValidationErrors, but got %v", err)
+	}
+	got := make([]string, 0)
+	for _, ve := range ves {
+		got = append(got, ve.Field+" "+ve.Rule+"="+strings.Join(ve.Params, " ")+": "+ve.Message)
+	}
+	expected := []string{
+		"name max=8: must be at most 8 characters long",
+		"email email=: must be a valid email address",
+		"plan oneof=free pro: must be one of free pro",
+		"confirm eqfield=Password: must be equal to Password",
+		"age min=18: must be at least 18",
+		"tags max=2: must have at most 2 items",
+		"end gtfield=Start: must be greater than Start",
+		"address.city required=: is required",
+		"items[1].qty min=1: must be at least 1",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expects errors:\n%s\nbut got:\n%s", strings.Join(expected, "\n"), strings.Join(got, "\n"))
+	}
+
+	if ve := ves.Field("plan"); ve == nil || ve.Rule != "oneof" {
+		t.Errorf("expects the error of plan, but got %v", ve)
+	}
+	if ve := ves.Field("password"); ve != nil {
+		t.Errorf("expects no error of password, but got %v", ve)
+	}
+}
+
+func TestValidateRequiredAndOmitempty(t *testing.T) {
+	type Form struct {
+		ID    *int   `query:"id" validate:"required,min=1"`
+		Limit *int   `query:"limit" validate:"min=1"`
+		Email string `query:"email" validate:"omitempty,email"`
+	}
+	err := Validate(Form{})
+	var ves ValidationErrors
+	if !errors.As(err, &ves) || len(ves) != 1 || ves[0].Field != "id" || ves[0].Rule != "required" {
+		t.Errorf("expects only id required, but got %v", err)
+	}
+}
+
+func TestValidateCustomRule(t *testing.T) {
+	if lookupRule("prefix") == nil {
+		RegisterRule("prefix", "must start with %s", func(f Field) bool {
+			return strings.HasPrefix(f.Value.String(), f.Params[0])
+		})
+	}
+	type Upload struct {
+		Name string `form:"name" validate:"prefix=img_"`
+	}
+	if err := Validate(&Upload{Name: "img_1.png"}); err != nil {
+		t.Errorf("expects no error, but got %v", err)
+	}
+	err := Validate(&Upload{Name: "1.png"})
+	if err == nil || err.Error() != "name must start with img_" {
+		t.Errorf("expects the custom rule to fail, but got %v", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expects a panic of registering a built-in rule")
+		}
+	}()
+	RegisterRule("email", "taken", func(Field) bool { return true })
+}
+
+func TestValidateErrors(t *testing.T) {
+	type Bad struct {
+		Name string `validate:"nope"`
+	}
+	if err := Validate(Bad{}); err == nil || errors.As(err, new(ValidationErrors)) {
+		t.Errorf("expects an error of the unknown rule, but got %v", err)
+	}
+	if err := Validate(1); err == nil {
+		t.Errorf("expects an error of a non-struct")
+	}
+
+	for _, v := range []interface{}{
+		&struct {
+			N int `validate:"max=abc"`
+		}{},
+		&struct {
+			S string `validate:"len="`
+		}{},
+		&struct {
+			S string `validate:"min=abc"`
+		}{S: "x"},
+		&struct {
+			S string `validate:"oneof="`
+		}{},
+		&struct {
+			S string `validate:"eqfield=Nope"`
+		}{},
+		&struct {
+			S *string `validate:"omitempty,max=1 2"`
+		}{},
+	} {
+		err := Validate(v)
+		if err == nil || errors.As(err, new(ValidationErrors)) || !strings.Contains(err.Error(), "invalid params") {
+			t.Errorf("%+v expects an error of the invalid params, but got %v", v, err)
+		}
+	}
+}
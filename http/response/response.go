@@ -7,6 +7,8 @@ import (
 	"errors"
 	"html/template"
 	"net/http"
+
+	"github.com/ggicci/jungo/http/request"
 )
 
 func WriteJSON(rw http.ResponseWriter, v interface{}) error {
@@ -36,3 +38,60 @@ func WriteXML(rw http.ResponseWriter, v interface{}) error {
 	rw.Header().Set("Content-Type", "application/xml;charset=utf-8")
 	return encoder.Encode(v)
 }
+
+// The error of a field in `ErrorBody`, of `request.FieldErrors` (with the
+// source and the value) or `request.ValidationErrors` (with the rule and the
+// params).
+type FieldErrorBody struct {
+	Field   string   `json:"field"`
+	Source  string   `json:"source,omitempty"`
+	Value   string   `json:"value,omitempty"`
+	Rule    string   `json:"rule,omitempty"`
+	Params  []string `json:"params,omitempty"`
+	Message string   `json:"message"`
+}
+
+// The JSON body written by `WriteError`, e.g.
+// {"error":"invalid request","fields":[{"field":"email","rule":"email","message":"must be a valid email address"}]}
+type ErrorBody struct {
+	Error  string           `json:"error"`
+	Fields []FieldErrorBody `json:"fields,omitempty"`
+}
+
+// Make the body of the error, the errors of the fields of `request.Bind` and
+// `request.Validate` are listed one by one.
+func NewErrorBody(err error) *ErrorBody {
+	var fes request.FieldErrors
+	var ves request.ValidationErrors
+	switch {
+	case errors.As(err, &fes):
+		body := &ErrorBody{Error: "invalid request", Fields: make([]FieldErrorBody, 0, len(fes))}
+		for _, fe := range fes {
+			body.Fields = append(body.Fields, FieldErrorBody{Field: fe.Field, Source: fe.Source, Value: fe.Value, Message: fe.Message})
+		}
+		return body
+	case errors.As(err, &ves):
+		body := &ErrorBody{Error: "invalid request", Fields: make([]FieldErrorBody, 0, len(ves))}
+		for _, ve := range ves {
+			body.Fields = append(body.Fields, FieldErrorBody{Field: ve.Field, Rule: ve.Rule, Params: ve.Params, Message: ve.Message})
+		}
+		return body
+	}
+	return &ErrorBody{Error: err.Error()}
+}
+
+// Write the error as `ErrorBody` with the status code, e.g.
+//
+//	if err := request.Bind(r, &form); err != nil {
+//		response.WriteError(rw, http.StatusBadRequest, err)
+//		return
+//	}
+//	if err := request.Validate(&form); err != nil {
+//		response.WriteError(rw, http.StatusUnprocessableEntity, err)
+//		return
+//	}
+func WriteError(rw http.ResponseWriter, code int, err error) error {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(code)
+	return json.NewEncoder(rw).Encode(NewErrorBody(err))
+}